@@ -2,9 +2,13 @@ package hemlock
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/gschier/hemlock/interfaces"
 	"github.com/gschier/hemlock/internal/container"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
@@ -14,6 +18,10 @@ import (
 	"strings"
 )
 
+// RoutesFileEnv is set by `hemlock routes`. Start writes the route table to
+// the file it names as JSON instead of listening.
+const RoutesFileEnv = "HEMLOCK_ROUTES_FILE"
+
 type Application struct {
 	Config    *Config
 	container *container.Container
@@ -32,6 +40,15 @@ func NewApplication(config *Config, providers []Provider) *Application {
 		if !u.IsAbs() && !strings.HasPrefix(config.PublicPrefix, "/") {
 			config.PublicPrefix = "/" + config.PublicPrefix
 		}
+
+		// Use a throwaway key in dev so signed cookies work out of the box.
+		// Providers that need a key fail to boot without one in production.
+		if config.Key == "" && strings.ToLower(config.Env) != "production" {
+			b := make([]byte, 32)
+			if _, err := rand.Read(b); err == nil {
+				config.Key = hex.EncodeToString(b)
+			}
+		}
 	}()
 
 	// Create the app
@@ -73,19 +90,33 @@ func NewApplication(config *Config, providers []Provider) *Application {
 }
 
 func CloneApplication(app *Application) *Application {
-	newApp := &Application{Config: app.Config}
+	newApp := &Application{Config: app.Config, ctx: app.ctx}
 
 	// Ensure all constructors take in *Application as an argument
 	serviceConstructorArgs := []interface{}{newApp}
 	newApp.container = container.Clone(app.container, serviceConstructorArgs)
 
-	return app
+	// Services resolving *Application should get the clone
+	newApp.Instance(newApp)
+
+	return newApp
 }
 
 func (a *Application) Start() {
 	var r interfaces.Router
 	a.Resolve(&r)
 
+	if p := os.Getenv(RoutesFileEnv); p != "" {
+		b, err := json.Marshal(r.Routes())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, b, 0644); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// TODO: Move this into a provider
 	server := &http.Server{
 		Addr:    a.Config.HTTP.Host + ":" + a.Config.HTTP.Port,
@@ -126,10 +157,30 @@ func (a *Application) ResolveInto(fn interface{}, extraArgs ...interface{}) []in
 	return a.container.Call(fn, extraArgs)
 }
 
+// ResolveIntoWith is like ResolveInto but asks resolver for each argument
+// before falling back to the container. If resolver returns an error, fn is
+// not called.
+func (a *Application) ResolveIntoWith(
+	fn interface{},
+	resolver func(argType reflect.Type) (reflect.Value, bool, error),
+) ([]interface{}, error) {
+	return a.container.CallWith(fn, resolver)
+}
+
 func (a *Application) Make(i interface{}) interface{} {
 	return a.container.Make(i)
 }
 
+// Has returns whether something is bound that can be resolved into i
+func (a *Application) Has(i interface{}) bool {
+	return a.container.Has(i)
+}
+
+// Bindings describes everything registered in the container
+func (a *Application) Bindings() []string {
+	return a.container.Bindings()
+}
+
 func (a *Application) Resolve(v ...interface{}) {
 	for i := 0; i < len(v); i++ {
 		a.container.Resolve(v[i])
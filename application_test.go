@@ -2,6 +2,8 @@ package hemlock_test
 
 import (
 	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/providers"
+	routeproviders "github.com/gschier/hemlock/support/providers"
 	. "github.com/gschier/hemlock/testutil"
 	"github.com/stretchr/testify/assert"
 	"os"
@@ -90,3 +92,27 @@ func TestContainer_MakeInstance(t *testing.T) {
 	assert.True(t, instance == instance1, "Should be new instance")
 	assert.True(t, instance1 == instance2, "Should be new instance")
 }
+
+func TestApplication_Key(t *testing.T) {
+	config := func() *hemlock.Config {
+		return &hemlock.Config{Env: "production", PublicPrefix: "/static"}
+	}
+
+	app := hemlock.NewApplication(config(), []hemlock.Provider{
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	})
+	assert.Equal(t, "", app.Config.Key, "Should boot without a key in production")
+
+	assert.PanicsWithValue(t, "Failed to boot SessionProvider: sessions require the app Key to be set\n", func() {
+		hemlock.NewApplication(config(), []hemlock.Provider{
+			new(providers.TemplateFuncsProvider),
+			new(providers.TemplatesProvider),
+			new(routeproviders.RouteProvider),
+			new(providers.SessionProvider),
+		})
+	}, "Should fail to boot sessions without a key in production")
+
+	assert.NotEqual(t, "", NewTestApplication().Config.Key, "Should generate a key in dev")
+}
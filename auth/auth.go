@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/gschier/hemlock/interfaces"
+)
+
+type contextKey string
+
+const (
+	authContextKey    = contextKey("auth")
+	managerContextKey = contextKey("manager")
+)
+
+// Authenticatable is a user that can be authenticated by a Guard
+type Authenticatable interface {
+	// AuthID returns the unique identifier of the user
+	AuthID() string
+}
+
+// UserProvider is implemented by the application to look up users. Each
+// method should return a nil user (and no error) when nothing matches.
+type UserProvider interface {
+	// RetrieveByID returns the user with the provided identifier
+	RetrieveByID(id string) (Authenticatable, error)
+
+	// RetrieveByToken returns the user owning the provided API token
+	RetrieveByToken(token string) (Authenticatable, error)
+
+	// RetrieveByCredentials returns the user matching the provided username
+	// and password
+	RetrieveByCredentials(username, password string) (Authenticatable, error)
+}
+
+// ErrNotStateful is returned when logging in or out with a guard that
+// doesn't remember users between requests
+var ErrNotStateful = errors.New("guard does not support login")
+
+// Auth provides access to the authenticated user of the current request
+type Auth struct {
+	manager *Manager
+	req     interfaces.Request
+	guard   string
+
+	user     Authenticatable
+	resolved bool
+}
+
+// FromRequest returns the Auth for the request. If the request passed
+// through the Required middleware, the user it authenticated is used.
+func FromRequest(m *Manager, req interfaces.Request) *Auth {
+	if a, ok := req.Context().Value(authContextKey).(*Auth); ok {
+		return a
+	}
+
+	return &Auth{manager: m, req: req, guard: m.DefaultGuard}
+}
+
+// Current returns the Auth for the request using the Manager installed by
+// the AuthProvider, or nil if the provider isn't registered
+func Current(req interfaces.Request) *Auth {
+	m := managerFromContext(req.Context())
+	if m == nil {
+		return nil
+	}
+	return FromRequest(m, req)
+}
+
+// User returns the authenticated user, or nil if there isn't one
+func (a *Auth) User() Authenticatable {
+	if a.resolved {
+		return a.user
+	}
+
+	a.resolved = true
+	g := a.manager.Guard(a.guard)
+	if g == nil {
+		return nil
+	}
+
+	user, err := g.User(a.req)
+	if err != nil {
+		return nil
+	}
+
+	a.user = user
+	return a.user
+}
+
+// Check returns whether there is an authenticated user
+func (a *Auth) Check() bool {
+	return a.User() != nil
+}
+
+// Login remembers the user for subsequent requests
+func (a *Auth) Login(user Authenticatable) error {
+	g, ok := a.manager.Guard(a.guard).(StatefulGuard)
+	if !ok {
+		return ErrNotStateful
+	}
+
+	err := g.Login(a.req, user)
+	if err != nil {
+		return err
+	}
+
+	a.user = user
+	a.resolved = true
+	return nil
+}
+
+// Logout forgets the authenticated user
+func (a *Auth) Logout() error {
+	g, ok := a.manager.Guard(a.guard).(StatefulGuard)
+	if !ok {
+		return ErrNotStateful
+	}
+
+	err := g.Logout(a.req)
+	if err != nil {
+		return err
+	}
+
+	a.user = nil
+	a.resolved = true
+	return nil
+}
+
+// Guard returns an Auth that authenticates with the named guard
+func (a *Auth) Guard(name string) *Auth {
+	return &Auth{manager: a.manager, req: a.req, guard: name}
+}
+
+func managerFromContext(ctx context.Context) *Manager {
+	m, _ := ctx.Value(managerContextKey).(*Manager)
+	return m
+}
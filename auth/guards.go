@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/session"
+	"strings"
+)
+
+// Guard authenticates the user making a request
+type Guard interface {
+	// User returns the user making the request, or nil if there isn't one
+	User(req interfaces.Request) (Authenticatable, error)
+}
+
+// StatefulGuard is a Guard that remembers users between requests
+type StatefulGuard interface {
+	Guard
+
+	// Login remembers the user for subsequent requests
+	Login(req interfaces.Request, user Authenticatable) error
+
+	// Logout forgets the current user
+	Logout(req interfaces.Request) error
+}
+
+var errNoSession = errors.New("session guard requires the SessionProvider")
+
+// SessionGuard authenticates users by an ID stored in the session
+type SessionGuard struct {
+	Users UserProvider
+	Key   string
+}
+
+func (g *SessionGuard) User(req interfaces.Request) (Authenticatable, error) {
+	sess := session.FromContext(req.Context())
+	if sess == nil {
+		return nil, errNoSession
+	}
+
+	id := sess.Get(g.Key)
+	if id == "" {
+		return nil, nil
+	}
+
+	return g.Users.RetrieveByID(id)
+}
+
+func (g *SessionGuard) Login(req interfaces.Request, user Authenticatable) error {
+	sess := session.FromContext(req.Context())
+	if sess == nil {
+		return errNoSession
+	}
+
+	sess.Regenerate()
+	sess.Put(g.Key, user.AuthID())
+	return nil
+}
+
+func (g *SessionGuard) Logout(req interfaces.Request) error {
+	sess := session.FromContext(req.Context())
+	if sess == nil {
+		return errNoSession
+	}
+
+	sess.Invalidate()
+	return nil
+}
+
+// TokenGuard authenticates users by a bearer token in the Authorization
+// header or an api_token query parameter
+type TokenGuard struct {
+	Users UserProvider
+}
+
+func (g *TokenGuard) User(req interfaces.Request) (Authenticatable, error) {
+	token := req.Query("api_token")
+	if h := req.Header("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+
+	if token == "" {
+		return nil, nil
+	}
+
+	return g.Users.RetrieveByToken(token)
+}
+
+// BasicGuard authenticates users with HTTP Basic credentials
+type BasicGuard struct {
+	Users UserProvider
+	Realm string
+}
+
+func (g *BasicGuard) User(req interfaces.Request) (Authenticatable, error) {
+	h := req.Header("Authorization")
+	if !strings.HasPrefix(h, "Basic ") {
+		return nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(h, "Basic "))
+	if err != nil {
+		return nil, nil
+	}
+
+	credentials := strings.SplitN(string(decoded), ":", 2)
+	if len(credentials) != 2 {
+		return nil, nil
+	}
+
+	return g.Users.RetrieveByCredentials(credentials[0], credentials[1])
+}
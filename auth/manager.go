@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/router"
+	"net/http"
+)
+
+// Manager holds the guards available to the application
+type Manager struct {
+	// DefaultGuard is used when no guard is named. Defaults to "session".
+	DefaultGuard string
+
+	// LoginURL is where unauthenticated session users are redirected. If
+	// empty, a 401 response is sent instead.
+	LoginURL string
+
+	guards map[string]Guard
+}
+
+// NewManager creates a Manager with "session", "token" and "basic" guards
+// backed by the provided UserProvider
+func NewManager(users UserProvider) *Manager {
+	m := &Manager{DefaultGuard: "session", guards: make(map[string]Guard)}
+	m.Extend("session", &SessionGuard{Users: users, Key: "auth_id"})
+	m.Extend("token", &TokenGuard{Users: users})
+	m.Extend("basic", &BasicGuard{Users: users, Realm: "Restricted"})
+	return m
+}
+
+// Extend adds or replaces a named guard
+func (m *Manager) Extend(name string, g Guard) {
+	m.guards[name] = g
+}
+
+// Guard returns the named guard, or nil if it doesn't exist
+func (m *Manager) Guard(name string) Guard {
+	return m.guards[name]
+}
+
+// Middleware makes the Manager available to the Required middleware
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), managerContextKey, m)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Required rejects requests that aren't authenticated by any of the named
+// guards (or the default guard if none are named). The authenticated user is
+// bound into the container so route callbacks can take it as an argument.
+//
+// For example:
+//
+//	r.With(auth.Required("token")).Get("/api/me", func(u *models.User) { ... })
+func Required(guards ...string) interfaces.Middleware {
+	return func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+		m := managerFromContext(req.Context())
+		if m == nil {
+			panic("auth.Required used without the AuthProvider")
+		}
+
+		names := guards
+		if len(names) == 0 {
+			names = []string{m.DefaultGuard}
+		}
+
+		for _, name := range names {
+			a := &Auth{manager: m, req: req, guard: name}
+			user := a.User()
+			if user == nil {
+				continue
+			}
+
+			ctx := context.WithValue(req.Context(), authContextKey, a)
+			ctx = router.WithInstance(ctx, user)
+			newReq := req.WithContext(ctx)
+			a.req = newReq
+			return next(newReq, res)
+		}
+
+		return m.unauthenticated(req, res, names[len(names)-1])
+	}
+}
+
+func (m *Manager) unauthenticated(req interfaces.Request, res interfaces.Response, guard string) interfaces.Result {
+	switch g := m.Guard(guard).(type) {
+	case *SessionGuard:
+		if m.LoginURL != "" {
+			return res.Redirect(m.LoginURL, http.StatusFound)
+		}
+	case *BasicGuard:
+		res.Header("WWW-Authenticate", `Basic realm="`+g.Realm+`"`)
+	}
+
+	return res.Error(hemlock.Unauthorized())
+}
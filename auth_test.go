@@ -0,0 +1,321 @@
+package hemlock_test
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/auth"
+	"github.com/gschier/hemlock/gate"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/providers"
+	routeproviders "github.com/gschier/hemlock/support/providers"
+	. "github.com/gschier/hemlock/testutil"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	ID string
+}
+
+func (u *testUser) AuthID() string {
+	return u.ID
+}
+
+type testUserProvider struct{}
+
+func (p *testUserProvider) RetrieveByID(id string) (auth.Authenticatable, error) {
+	if id != "1" {
+		return nil, nil
+	}
+	return &testUser{ID: id}, nil
+}
+
+func (p *testUserProvider) RetrieveByToken(token string) (auth.Authenticatable, error) {
+	if token != "secret" {
+		return nil, nil
+	}
+	return &testUser{ID: "1"}, nil
+}
+
+func (p *testUserProvider) RetrieveByCredentials(username, password string) (auth.Authenticatable, error) {
+	if username != "user" || password != "pass" {
+		return nil, nil
+	}
+	return &testUser{ID: "1"}, nil
+}
+
+type testUserProviderProvider struct{}
+
+func (p *testUserProviderProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (auth.UserProvider, error) {
+		return &testUserProvider{}, nil
+	})
+}
+
+func (p *testUserProviderProvider) Boot(app *hemlock.Application) error {
+	return nil
+}
+
+type testPost struct {
+	AuthorID string
+}
+
+type testPostPolicy struct{}
+
+func (p *testPostPolicy) Update(u *testUser, post *testPost) bool {
+	return post.AuthorID == u.ID
+}
+
+func newAuthTestRouter() interfaces.Router {
+	app := newAuthTestApplication()
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	return r
+}
+
+func newAuthTestApplication() *hemlock.Application {
+	return NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+		new(providers.SessionProvider),
+		new(testUserProviderProvider),
+		new(providers.AuthProvider),
+		new(providers.GateProvider),
+	)
+}
+
+func TestAuth_SessionLogin(t *testing.T) {
+	r := newAuthTestRouter()
+	r.Post("/login", func(a *auth.Auth, res interfaces.Response) interfaces.Result {
+		assert.False(t, a.Check(), "Should not be logged in yet")
+		assert.NoError(t, a.Login(&testUser{ID: "1"}))
+		return res.Data("ok")
+	})
+	r.With(auth.Required()).Get("/me", func(u *testUser, res interfaces.Response) interfaces.Result {
+		return res.Data(u.ID)
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Should reject guests")
+	cookies := w.Result().Cookies()
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.Header.Set("X-XSRF-Token", findCookie(cookies, "XSRF-TOKEN").Value)
+	addCookies(req, cookies)
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	cookies = w.Result().Cookies()
+
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	addCookies(req, cookies)
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Should remember user")
+	assert.Equal(t, "1", w.Body.String(), "Should inject user")
+}
+
+func TestCSRF(t *testing.T) {
+	r := newAuthTestRouter()
+	cb := func(res interfaces.Response) interfaces.Result {
+		return res.Data("ok")
+	}
+	r.Get("/form", cb)
+	r.Post("/submit", cb)
+	r.Post("/webhook", cb).CSRFExempt()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "Should allow reads")
+	cookies := w.Result().Cookies()
+	token := findCookie(cookies, "XSRF-TOKEN").Value
+
+	req := httptest.NewRequest(http.MethodPost, "/submit", nil)
+	addCookies(req, cookies)
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, 419, w.Code, "Should reject missing token")
+
+	req = httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader("_token="+token))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	addCookies(req, cookies)
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Should accept form token")
+
+	req = httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.Header.Set("X-CSRF-Token", token)
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, 419, w.Code, "Should reject token from another session")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "Should skip exempt routes")
+
+	req = httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Should skip token requests without a session")
+
+	req = httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.SetBasicAuth("user", "pass")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, 419, w.Code, "Should check basic auth requests since browsers send them cross-site")
+
+	req = httptest.NewRequest(http.MethodPost, "/submit", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	addCookies(req, cookies)
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, 419, w.Code, "Should check token requests with a session")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "Should skip requests without a route")
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return &http.Cookie{}
+}
+
+func addCookies(req *http.Request, cookies []*http.Cookie) {
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+}
+
+func TestAuth_TokenAndBasic(t *testing.T) {
+	r := newAuthTestRouter()
+	r.With(auth.Required("token", "basic")).Get("/api", func(u auth.Authenticatable, res interfaces.Response) interfaces.Result {
+		return res.Data(u.AuthID())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Should accept token")
+
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.SetBasicAuth("user", "pass")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Should accept basic credentials")
+
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.SetBasicAuth("user", "wrong")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Should reject bad credentials")
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Basic")
+}
+
+func TestGate_Abilities(t *testing.T) {
+	app := newAuthTestApplication()
+
+	g := app.Make(new(gate.Gate)).(*gate.Gate)
+	g.Define("view-admin", func(u *testUser) bool {
+		return u.ID == "1"
+	})
+	g.Policy(&testPost{}, &testPostPolicy{})
+
+	user := &testUser{ID: "1"}
+	assert.True(t, g.Allows(user, "view-admin"), "Should allow defined ability")
+	assert.False(t, g.Allows(&testUser{ID: "2"}, "view-admin"), "Should deny defined ability")
+	assert.False(t, g.Allows(nil, "view-admin"), "Should deny guests")
+	assert.False(t, g.Allows(user, "missing"), "Should deny undefined ability")
+	assert.True(t, g.Allows(user, "update", &testPost{AuthorID: "1"}), "Should allow by policy")
+	assert.False(t, g.Allows(user, "update", &testPost{AuthorID: "2"}), "Should deny by policy")
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	r.With(gate.Can("view-admin")).Get("/admin", func(res interfaces.Response) interfaces.Result {
+		return res.Data("ok")
+	})
+	r.With(auth.Required("token"), gate.Can("view-admin")).Get("/api/admin", func(res interfaces.Response) interfaces.Result {
+		return res.Data("ok")
+	})
+	r.Get("/posts", func(g *gate.UserGate, res interfaces.Response) interfaces.Result {
+		if denied := g.Authorize(res, "update", &testPost{AuthorID: "1"}); denied != nil {
+			return denied
+		}
+		return res.Data("ok")
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code, "Should forbid guests")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code, "Should forbid guests")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin?api_token=secret", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "Should allow user authenticated by guard")
+}
+
+func TestGate_Templates(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "templates-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "views"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "partials"), 0755))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "views", "admin.html"),
+		[]byte(`{{ if can "view-admin" }}admin{{ else }}guest{{ end }} {{ partial "badge.html" }}`),
+		0644,
+	))
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "partials", "badge.html"),
+		[]byte(`{{ if can "view-admin" }}badge{{ else }}none{{ end }}`),
+		0644,
+	))
+
+	app := hemlock.NewApplication(&hemlock.Config{PublicPrefix: "/static", TemplatesDirectory: dir}, []hemlock.Provider{
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+		new(providers.SessionProvider),
+		new(testUserProviderProvider),
+		new(providers.AuthProvider),
+		new(providers.GateProvider),
+	})
+	app.Make(new(gate.Gate)).(*gate.Gate).Define("view-admin", func(u *testUser) bool {
+		return u.ID == "1"
+	})
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	page := func(res interfaces.Response) interfaces.Result {
+		return res.View("admin.html", "", nil)
+	}
+	r.Get("/page", page)
+	r.With(auth.Required("token")).Get("/api/page", page)
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Body.String()
+	}
+
+	assert.Equal(t, "admin badge", get("/api/page?api_token=secret"), "Should use the request's user in views and partials")
+	assert.Equal(t, "guest none", get("/page"), "Should not keep the last request's user")
+	assert.Equal(t, "admin badge", get("/api/page?api_token=secret"))
+}
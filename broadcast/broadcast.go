@@ -0,0 +1,341 @@
+// Package broadcast publishes events to named channels that clients
+// subscribe to over WebSockets or Server-Sent Events
+package broadcast
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gschier/hemlock/auth"
+	"github.com/gschier/hemlock/interfaces"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Channel name prefixes that need authorization. Presence channels also
+// tell subscribers who else is subscribed.
+const (
+	PrivatePrefix  = "private-"
+	PresencePrefix = "presence-"
+)
+
+// Events sent to subscribers by the broadcaster itself
+const (
+	SubscribedEvent        = "broadcast:subscribed"
+	UnsubscribedEvent      = "broadcast:unsubscribed"
+	SubscriptionErrorEvent = "broadcast:subscription_error"
+	MembersEvent           = "broadcast:members"
+	MemberAddedEvent       = "broadcast:member_added"
+	MemberRemovedEvent     = "broadcast:member_removed"
+)
+
+// ErrForbidden is returned when a user may not subscribe to a channel
+var ErrForbidden = errors.New("broadcast: not allowed to subscribe to channel")
+
+// DefaultPresenceInterval is how often presence channel members are joined
+// again to keep them from expiring
+const DefaultPresenceInterval = 20 * time.Second
+
+var boolType = reflect.TypeOf(true)
+
+// Event is published to a channel. Data is encoded as JSON.
+type Event struct {
+	Name string
+	Data interface{}
+}
+
+// Message is an event as it's sent through a Backend and to clients
+type Message struct {
+	Channel string          `json:"channel"`
+	Event   string          `json:"event"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Member is a user subscribed to a presence channel
+type Member struct {
+	ID   string          `json:"id"`
+	Info json.RawMessage `json:"info,omitempty"`
+}
+
+// Backend delivers messages between publishers and subscribers, which
+// could be on different servers
+type Backend interface {
+	Publish(msg Message) error
+
+	// Subscribe returns a Subscription receiving messages published to the
+	// channels from now on
+	Subscribe(channels ...string) (Subscription, error)
+
+	// Join adds a member to a presence channel for a single connection and
+	// returns whether it's the member's first. Users with more than one
+	// connection are listed once by Members. Joining again with the same
+	// connection renews it in backends that expire members.
+	Join(channel, connID string, m Member) (bool, error)
+
+	// Leave removes a connection from a presence channel and returns
+	// whether it was the member's last
+	Leave(channel, connID string, m Member) (bool, error)
+
+	Members(channel string) ([]Member, error)
+}
+
+type Subscription interface {
+	// Messages is closed when the Subscription is
+	Messages() <-chan Message
+	Close() error
+}
+
+// Broadcaster publishes events and authorizes subscriptions
+type Broadcaster struct {
+	// Guards are the auth guards tried in order to find the user
+	// subscribing to a channel. Defaults to the default guard.
+	Guards []string
+
+	// PresenceInterval is how often connections join their presence
+	// channels again while they're open. Defaults to
+	// DefaultPresenceInterval.
+	PresenceInterval time.Duration
+
+	backend  Backend
+	channels []*channelAuth
+	mutex    sync.RWMutex
+}
+
+type channelAuth struct {
+	segments []string
+	fn       reflect.Value
+}
+
+func New(backend Backend) *Broadcaster {
+	return &Broadcaster{backend: backend}
+}
+
+// Backend returns the Backend messages are delivered through
+func (b *Broadcaster) Backend() Backend {
+	return b.backend
+}
+
+// Channel authorizes subscriptions to private and presence channels
+// matching the pattern. The callback receives the user followed by the
+// pattern's params. Private channels return a bool, and presence channels
+// return info about the member, or nil to deny them. Guests are always
+// denied.
+//
+// For example:
+//
+//	b.Channel("orders.{id}", func(u *models.User, id string) bool {
+//		return u.OwnsOrder(id)
+//	})
+//	b.Channel("rooms.{id}", func(u *models.User, id string) interface{} {
+//		return map[string]string{"name": u.Name}
+//	})
+func (b *Broadcaster) Channel(pattern string, callback interface{}) {
+	fn := reflect.ValueOf(callback)
+	segments := strings.Split(pattern, ".")
+	if fn.Kind() != reflect.Func || fn.Type().NumIn() != 1+countParams(segments) || fn.Type().NumOut() != 1 {
+		panic("Channel " + pattern + " must be a func taking a user and its params and returning one value")
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.channels = append(b.channels, &channelAuth{segments: segments, fn: fn})
+}
+
+// To returns a channel to publish events to
+//
+// For example:
+//
+//	broadcast.To("private-orders.42").Send(broadcast.Event{Name: "shipped", Data: order})
+func (b *Broadcaster) To(channel string) *PendingBroadcast {
+	return &PendingBroadcast{broadcaster: b, channel: channel}
+}
+
+// PendingBroadcast is a channel events can be sent to
+type PendingBroadcast struct {
+	broadcaster *Broadcaster
+	channel     string
+}
+
+func (p *PendingBroadcast) Send(event Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	return p.broadcaster.backend.Publish(Message{Channel: p.channel, Event: event.Name, Data: data})
+}
+
+// Authorize returns whether the request's user may subscribe to the
+// channel. For presence channels, the member they'll be listed as is
+// returned too.
+func (b *Broadcaster) Authorize(req interfaces.Request, channel string) (*Member, error) {
+	name := channel
+	presence := strings.HasPrefix(channel, PresencePrefix)
+	switch {
+	case presence:
+		name = strings.TrimPrefix(channel, PresencePrefix)
+	case strings.HasPrefix(channel, PrivatePrefix):
+		name = strings.TrimPrefix(channel, PrivatePrefix)
+	default:
+		return nil, nil
+	}
+
+	user := b.user(req)
+	if user == nil {
+		return nil, ErrForbidden
+	}
+
+	c, params := b.match(name)
+	if c == nil {
+		return nil, ErrForbidden
+	}
+
+	out, ok := call(c.fn, user, params)
+	if !ok {
+		return nil, ErrForbidden
+	}
+
+	if out.Type() == boolType {
+		if !out.Bool() {
+			return nil, ErrForbidden
+		}
+		out = reflect.Value{}
+	} else if isNil(out) {
+		return nil, ErrForbidden
+	}
+
+	if !presence {
+		return nil, nil
+	}
+
+	m := &Member{ID: user.AuthID()}
+	if out.IsValid() {
+		info, err := json.Marshal(out.Interface())
+		if err != nil {
+			return nil, err
+		}
+		m.Info = info
+	}
+
+	return m, nil
+}
+
+func (b *Broadcaster) presenceInterval() time.Duration {
+	if b.PresenceInterval > 0 {
+		return b.PresenceInterval
+	}
+	return DefaultPresenceInterval
+}
+
+func (b *Broadcaster) user(req interfaces.Request) auth.Authenticatable {
+	a := auth.Current(req)
+	if a == nil {
+		return nil
+	}
+
+	if len(b.Guards) == 0 {
+		return notNil(a.User())
+	}
+
+	for _, g := range b.Guards {
+		if user := notNil(a.Guard(g).User()); user != nil {
+			return user
+		}
+	}
+
+	return nil
+}
+
+func (b *Broadcaster) match(name string) (*channelAuth, []string) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	segments := strings.Split(name, ".")
+	for _, c := range b.channels {
+		if len(c.segments) != len(segments) {
+			continue
+		}
+
+		var params []string
+		matched := true
+		for i, s := range c.segments {
+			if isParam(s) {
+				params = append(params, segments[i])
+			} else if s != segments[i] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return c, params
+		}
+	}
+
+	return nil, nil
+}
+
+// call calls fn with the user and params if the user's type lines up
+func call(fn reflect.Value, user auth.Authenticatable, params []string) (reflect.Value, bool) {
+	fnType := fn.Type()
+	u := reflect.ValueOf(user)
+	if !u.Type().AssignableTo(fnType.In(0)) {
+		return reflect.Value{}, false
+	}
+
+	in := []reflect.Value{u}
+	for i, p := range params {
+		if fnType.In(i+1).Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		in = append(in, reflect.ValueOf(p).Convert(fnType.In(i+1)))
+	}
+
+	return fn.Call(in)[0], true
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// notNil turns a nil pointer to a user into a nil interface
+func notNil(user auth.Authenticatable) auth.Authenticatable {
+	if user == nil || isNil(reflect.ValueOf(user)) {
+		return nil
+	}
+	return user
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+func countParams(segments []string) int {
+	n := 0
+	for _, s := range segments {
+		if isParam(s) {
+			n++
+		}
+	}
+	return n
+}
+
+// uniqueMembers lists each member once, keeping their first connection
+func uniqueMembers(members []Member) []Member {
+	seen := make(map[string]bool)
+	unique := make([]Member, 0, len(members))
+	for _, m := range members {
+		if !seen[m.ID] {
+			seen[m.ID] = true
+			unique = append(unique, m)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,159 @@
+package broadcast
+
+import (
+	"sort"
+	"sync"
+)
+
+// subscriptionBuffer is how many messages a subscriber can fall behind by
+// before messages to it are dropped
+const subscriptionBuffer = 256
+
+// MemoryBackend delivers messages within a single process
+type MemoryBackend struct {
+	subscriptions map[string]map[*memorySubscription]bool
+	members       map[string]map[string]Member
+	mutex         sync.RWMutex
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		subscriptions: make(map[string]map[*memorySubscription]bool),
+		members:       make(map[string]map[string]Member),
+	}
+}
+
+// Publish never blocks. Subscribers that have fallen too far behind miss
+// the message.
+func (b *MemoryBackend) Publish(msg Message) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for s := range b.subscriptions[msg.Channel] {
+		s.send(msg)
+	}
+
+	return nil
+}
+
+func (b *MemoryBackend) Subscribe(channels ...string) (Subscription, error) {
+	s := &memorySubscription{backend: b, channels: channels, messages: make(chan Message, subscriptionBuffer)}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, c := range channels {
+		if b.subscriptions[c] == nil {
+			b.subscriptions[c] = make(map[*memorySubscription]bool)
+		}
+		b.subscriptions[c][s] = true
+	}
+
+	return s, nil
+}
+
+// Join never expires members. They're only kept in this process, so
+// they're gone with it.
+func (b *MemoryBackend) Join(channel, connID string, m Member) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.members[channel] == nil {
+		b.members[channel] = make(map[string]Member)
+	}
+	_, rejoined := b.members[channel][connID]
+	first := !rejoined && b.connections(channel, m.ID) == 0
+	b.members[channel][connID] = m
+
+	return first, nil
+}
+
+func (b *MemoryBackend) Leave(channel, connID string, m Member) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, joined := b.members[channel][connID]
+	delete(b.members[channel], connID)
+	last := joined && b.connections(channel, m.ID) == 0
+	if len(b.members[channel]) == 0 {
+		delete(b.members, channel)
+	}
+
+	return last, nil
+}
+
+// connections counts the member's connections to the channel
+func (b *MemoryBackend) connections(channel, id string) int {
+	n := 0
+	for _, m := range b.members[channel] {
+		if m.ID == id {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *MemoryBackend) Members(channel string) ([]Member, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	// Sort connections so members are listed in a stable order
+	connIDs := make([]string, 0, len(b.members[channel]))
+	for id := range b.members[channel] {
+		connIDs = append(connIDs, id)
+	}
+	sort.Strings(connIDs)
+
+	members := make([]Member, len(connIDs))
+	for i, id := range connIDs {
+		members[i] = b.members[channel][id]
+	}
+
+	return uniqueMembers(members), nil
+}
+
+type memorySubscription struct {
+	backend  *MemoryBackend
+	channels []string
+	messages chan Message
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+func (s *memorySubscription) Messages() <-chan Message {
+	return s.messages
+}
+
+func (s *memorySubscription) Close() error {
+	s.backend.mutex.Lock()
+	for _, c := range s.channels {
+		delete(s.backend.subscriptions[c], s)
+		if len(s.backend.subscriptions[c]) == 0 {
+			delete(s.backend.subscriptions, c)
+		}
+	}
+	s.backend.mutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.messages)
+	}
+
+	return nil
+}
+
+func (s *memorySubscription) send(msg Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.messages <- msg:
+	default:
+	}
+}
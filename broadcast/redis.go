@@ -0,0 +1,419 @@
+package broadcast
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisPrefix namespaces the keys and channels used in Redis
+const redisPrefix = "hemlock:broadcast:"
+
+// DefaultPresenceTTL is how long a presence channel member is kept in Redis
+// without joining again
+const DefaultPresenceTTL = time.Minute
+
+// RedisBackend delivers messages between servers through Redis pub/sub,
+// and keeps presence channel members in Redis. It speaks just enough of
+// the Redis protocol for that, so any compatible server works.
+//
+// Each presence channel has a sorted set of connections scored by when
+// they expire, a hash of their members, and a sorted set of each member's
+// connections, so members on a server that went away without leaving
+// expire on their own.
+type RedisBackend struct {
+	// PresenceTTL is how long members are kept without joining again.
+	// Defaults to DefaultPresenceTTL, and should be a few times the
+	// Broadcaster's PresenceInterval.
+	PresenceTTL time.Duration
+
+	addr     string
+	password string
+	timeout  time.Duration
+
+	conn  *redisConn
+	mutex sync.Mutex
+}
+
+func NewRedisBackend(addr, password string) *RedisBackend {
+	return &RedisBackend{addr: addr, password: password, timeout: 5 * time.Second}
+}
+
+func (b *RedisBackend) Publish(msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.do("PUBLISH", redisPrefix+msg.Channel, string(payload))
+	return err
+}
+
+func (b *RedisBackend) Subscribe(channels ...string) (Subscription, error) {
+	conn, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"SUBSCRIBE"}
+	for _, c := range channels {
+		args = append(args, redisPrefix+c)
+	}
+
+	// Wait for every subscription to be confirmed so nothing published
+	// after Subscribe returns is missed
+	if err := conn.write(args...); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for range channels {
+		if _, err := conn.read(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	s := &redisSubscription{conn: conn, messages: make(chan Message, subscriptionBuffer)}
+	go s.listen()
+
+	return s, nil
+}
+
+// Join counts the member's connections in the same transaction as adding
+// this one, so only one of several joining at once is the first
+func (b *RedisBackend) Join(channel, connID string, m Member) (bool, error) {
+	member, err := json.Marshal(m)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	ttl := b.presenceTTL()
+	expires := millis(now.Add(ttl))
+	pttl := strconv.FormatInt(int64(ttl/time.Millisecond), 10)
+	conns, infos, userConns := presenceKeys(channel, m.ID)
+
+	replies, err := b.transaction(
+		[]string{"ZREMRANGEBYSCORE", userConns, "-inf", millis(now)},
+		[]string{"ZADD", userConns, expires, connID},
+		[]string{"ZCARD", userConns},
+		[]string{"ZADD", conns, expires, connID},
+		[]string{"HSET", infos, connID, string(member)},
+		[]string{"PEXPIRE", userConns, pttl},
+		[]string{"PEXPIRE", conns, pttl},
+		[]string{"PEXPIRE", infos, pttl},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	added, _ := replies[1].(int64)
+	count, _ := replies[2].(int64)
+	return added == 1 && count == 1, nil
+}
+
+func (b *RedisBackend) Leave(channel, connID string, m Member) (bool, error) {
+	conns, infos, userConns := presenceKeys(channel, m.ID)
+
+	replies, err := b.transaction(
+		[]string{"ZREMRANGEBYSCORE", userConns, "-inf", millis(time.Now())},
+		[]string{"ZREM", userConns, connID},
+		[]string{"ZCARD", userConns},
+		[]string{"ZREM", conns, connID},
+		[]string{"HDEL", infos, connID},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	removed, _ := replies[1].(int64)
+	count, _ := replies[2].(int64)
+	return removed == 1 && count == 0, nil
+}
+
+func (b *RedisBackend) Members(channel string) ([]Member, error) {
+	conns, infos, _ := presenceKeys(channel, "")
+
+	replies, err := b.transaction(
+		[]string{"ZREMRANGEBYSCORE", conns, "-inf", millis(time.Now())},
+		[]string{"ZRANGE", conns, "0", "-1"},
+		[]string{"HGETALL", infos},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make(map[string]bool)
+	ids, _ := replies[1].([]interface{})
+	for _, id := range ids {
+		connID, _ := id.(string)
+		live[connID] = true
+	}
+
+	fields, _ := replies[2].([]interface{})
+	members := make(map[string]Member)
+	connIDs := make([]string, 0, len(ids))
+	expired := []string{"HDEL", infos}
+	for i := 0; i+1 < len(fields); i += 2 {
+		connID, _ := fields[i].(string)
+		value, _ := fields[i+1].(string)
+		if !live[connID] {
+			expired = append(expired, connID)
+			continue
+		}
+
+		var m Member
+		if err := json.Unmarshal([]byte(value), &m); err != nil {
+			continue
+		}
+		members[connID] = m
+		connIDs = append(connIDs, connID)
+	}
+
+	// Clean up after connections that expired
+	if len(expired) > 2 {
+		if _, err := b.do(expired...); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sort connections so members are listed in a stable order
+	sort.Strings(connIDs)
+	list := make([]Member, len(connIDs))
+	for i, id := range connIDs {
+		list[i] = members[id]
+	}
+
+	return uniqueMembers(list), nil
+}
+
+func (b *RedisBackend) presenceTTL() time.Duration {
+	if b.PresenceTTL > 0 {
+		return b.PresenceTTL
+	}
+	return DefaultPresenceTTL
+}
+
+// presenceKeys returns the keys of a presence channel's connections, their
+// members, and the connections of the member with the ID
+func presenceKeys(channel, id string) (string, string, string) {
+	key := redisPrefix + "presence:" + channel
+	return key, key + ":members", key + ":user:" + id
+}
+
+// millis formats t as milliseconds since the epoch, which connections are
+// scored by
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// do sends a command on the shared connection
+func (b *RedisBackend) do(args ...string) (interface{}, error) {
+	return b.withConn(func(conn *redisConn) (interface{}, error) {
+		return conn.do(args...)
+	})
+}
+
+// transaction sends the commands in a MULTI block so they run together,
+// and returns their replies
+func (b *RedisBackend) transaction(commands ...[]string) ([]interface{}, error) {
+	reply, err := b.withConn(func(conn *redisConn) (interface{}, error) {
+		if _, err := conn.do("MULTI"); err != nil {
+			return nil, err
+		}
+		for _, args := range commands {
+			if _, err := conn.do(args...); err != nil {
+				_, _ = conn.do("DISCARD")
+				return nil, err
+			}
+		}
+		return conn.do("EXEC")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replies, _ := reply.([]interface{})
+	if len(replies) != len(commands) {
+		return nil, errors.New("redis: transaction failed")
+	}
+	return replies, nil
+}
+
+// withConn calls fn with the shared connection, reconnecting if the last
+// command broke it
+func (b *RedisBackend) withConn(fn func(conn *redisConn) (interface{}, error)) (interface{}, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.conn == nil {
+		conn, err := b.dial()
+		if err != nil {
+			return nil, err
+		}
+		b.conn = conn
+	}
+
+	_ = b.conn.SetDeadline(time.Now().Add(b.timeout))
+	reply, err := fn(b.conn)
+	if _, ok := err.(redisError); err != nil && !ok {
+		b.conn.Close()
+		b.conn = nil
+	}
+
+	return reply, err
+}
+
+func (b *RedisBackend) dial() (*redisConn, error) {
+	netConn, err := net.DialTimeout("tcp", b.addr, b.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{Conn: netConn, r: bufio.NewReader(netConn)}
+	_ = conn.SetDeadline(time.Now().Add(b.timeout))
+
+	if b.password != "" {
+		if _, err := conn.do("AUTH", b.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+type redisSubscription struct {
+	conn     *redisConn
+	messages chan Message
+}
+
+func (s *redisSubscription) Messages() <-chan Message {
+	return s.messages
+}
+
+func (s *redisSubscription) Close() error {
+	return s.conn.Close()
+}
+
+// listen delivers messages until the connection is closed. Like the
+// MemoryBackend, messages are dropped if the subscriber falls behind.
+func (s *redisSubscription) listen() {
+	defer close(s.messages)
+
+	for {
+		reply, err := s.conn.read()
+		if err != nil {
+			return
+		}
+
+		// Pushed messages look like ["message", channel, payload]
+		parts, _ := reply.([]interface{})
+		if len(parts) != 3 || parts[0] != "message" {
+			continue
+		}
+		payload, _ := parts[2].(string)
+
+		var msg Message
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			continue
+		}
+
+		select {
+		case s.messages <- msg:
+		default:
+		}
+	}
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	if err := c.write(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// write sends a command as an array of bulk strings
+func (c *redisConn) write(args ...string) error {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(a))+"\r\n"+a+"\r\n"...)
+	}
+
+	_, err := c.Write(buf)
+	return err
+}
+
+// read reads a reply. Bulk and simple strings are returned as strings,
+// integers as int64 and arrays as []interface{}. Error replies are
+// returned as a redisError.
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := c.read()
+			if _, ok := err.(redisError); err != nil && !ok {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+}
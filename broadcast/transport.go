@@ -0,0 +1,335 @@
+package broadcast
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"path"
+	"sync"
+	"time"
+)
+
+// Routes adds the endpoints clients subscribe through under prefix:
+//
+// GET prefix/events?channel=a&channel=b streams messages from the channels
+// as Server-Sent Events named after each message's event. It fails with a
+// 403 if the user may not subscribe to one of them.
+//
+// prefix/socket is a WebSocket that takes messages like
+// {"action": "subscribe", "channel": "orders.42"} and "unsubscribe". Each is
+// answered with a SubscribedEvent, UnsubscribedEvent or
+// SubscriptionErrorEvent, and messages from every subscribed channel are
+// sent as they're published.
+//
+// Presence channels are sent a MembersEvent listing who's subscribed when
+// joining, then MemberAddedEvent and MemberRemovedEvent as that changes.
+func (b *Broadcaster) Routes(router interfaces.Router, prefix string) {
+	router.Get(path.Join(prefix, "events"), b.serveEvents).Name("broadcast.events")
+	router.WebSocket(path.Join(prefix, "socket"), b.serveSocket).Name("broadcast.socket")
+}
+
+func (b *Broadcaster) serveEvents(req interfaces.Request, res interfaces.Response) interfaces.Result {
+	channels := req.URL().Query()["channel"]
+	if len(channels) == 0 {
+		return res.Error(hemlock.BadRequest().WithMessage("No channel given"))
+	}
+
+	members := make(map[string]*Member)
+	for _, c := range channels {
+		m, err := b.Authorize(req, c)
+		if err == ErrForbidden {
+			return res.Error(hemlock.Forbidden())
+		} else if err != nil {
+			return res.Error(err)
+		}
+		members[c] = m
+	}
+
+	return res.Stream(func(w interfaces.EventWriter) error {
+		sub, err := b.backend.Subscribe(channels...)
+		if err != nil {
+			return err
+		}
+		defer sub.Close()
+
+		connID := newConnID()
+		joined := make(map[string]Member)
+		for _, c := range channels {
+			if members[c] == nil {
+				continue
+			}
+
+			list, err := b.join(c, connID, *members[c])
+			if err != nil {
+				return err
+			}
+			joined[c] = *members[c]
+			defer b.leave(c, connID, *members[c])
+
+			if err := w.Send(interfaces.Event{Event: MembersEvent, Data: membersMessage(c, list)}); err != nil {
+				return err
+			}
+		}
+
+		renew := time.NewTicker(b.presenceInterval())
+		defer renew.Stop()
+
+		for {
+			select {
+			case <-w.Context().Done():
+				return nil
+			case <-renew.C:
+				b.renew(connID, joined)
+			case msg, ok := <-sub.Messages():
+				if !ok {
+					return nil
+				}
+				if isOwnJoin(msg, members[msg.Channel]) {
+					continue
+				}
+				if err := w.Send(interfaces.Event{Event: msg.Event, Data: msg}); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+type socketCommand struct {
+	Action  string `json:"action"`
+	Channel string `json:"channel"`
+}
+
+func (b *Broadcaster) serveSocket(req interfaces.Request, conn interfaces.WebSocketConn) error {
+	s := &socket{
+		broadcaster:   b,
+		req:           req,
+		conn:          conn,
+		connID:        newConnID(),
+		subscriptions: make(map[string]Subscription),
+		members:       make(map[string]Member),
+	}
+	defer s.unsubscribeAll()
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.renew(done)
+
+	for {
+		// Whatever went wrong, the connection is gone
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil
+		}
+
+		var cmd socketCommand
+		switch {
+		case json.Unmarshal(data, &cmd) != nil:
+			err = s.fail("", "Invalid message")
+		case cmd.Action == "subscribe":
+			err = s.subscribe(cmd.Channel)
+		case cmd.Action == "unsubscribe":
+			err = s.unsubscribe(cmd.Channel)
+		default:
+			err = s.fail(cmd.Channel, "Unknown action")
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// socket tracks the channels a WebSocket is subscribed to
+type socket struct {
+	broadcaster   *Broadcaster
+	req           interfaces.Request
+	conn          interfaces.WebSocketConn
+	connID        string
+	subscriptions map[string]Subscription
+	wg            sync.WaitGroup
+
+	// members is renewed in the background, so it's guarded by mutex
+	members map[string]Member
+	mutex   sync.Mutex
+}
+
+func (s *socket) subscribe(channel string) error {
+	if _, ok := s.subscriptions[channel]; ok {
+		return s.conn.WriteJSON(Message{Channel: channel, Event: SubscribedEvent})
+	}
+
+	m, err := s.broadcaster.Authorize(s.req, channel)
+	if err == ErrForbidden {
+		return s.fail(channel, "Forbidden")
+	} else if err != nil {
+		return err
+	}
+
+	sub, err := s.broadcaster.backend.Subscribe(channel)
+	if err != nil {
+		return err
+	}
+	s.subscriptions[channel] = sub
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for msg := range sub.Messages() {
+			if !isOwnJoin(msg, m) {
+				_ = s.conn.WriteJSON(msg)
+			}
+		}
+	}()
+
+	if err := s.conn.WriteJSON(Message{Channel: channel, Event: SubscribedEvent}); err != nil {
+		return err
+	}
+
+	if m == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	list, err := s.broadcaster.join(channel, s.connID, *m)
+	if err == nil {
+		s.members[channel] = *m
+	}
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return s.conn.WriteJSON(membersMessage(channel, list))
+}
+
+func (s *socket) unsubscribe(channel string) error {
+	if err := s.leave(channel); err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(Message{Channel: channel, Event: UnsubscribedEvent})
+}
+
+func (s *socket) unsubscribeAll() {
+	for c := range s.subscriptions {
+		_ = s.leave(c)
+	}
+	s.wg.Wait()
+}
+
+func (s *socket) leave(channel string) error {
+	sub, ok := s.subscriptions[channel]
+	if !ok {
+		return nil
+	}
+
+	delete(s.subscriptions, channel)
+	if err := sub.Close(); err != nil {
+		return err
+	}
+
+	// Hold the lock while leaving so a renewal can't join again after
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if m, ok := s.members[channel]; ok {
+		delete(s.members, channel)
+		return s.broadcaster.leave(channel, s.connID, m)
+	}
+
+	return nil
+}
+
+// renew keeps the socket's presence channel memberships from expiring
+// until done is closed
+func (s *socket) renew(done <-chan struct{}) {
+	ticker := time.NewTicker(s.broadcaster.presenceInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			s.broadcaster.renew(s.connID, s.members)
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// fail tells the client a command didn't work. It only returns an error
+// if the client couldn't be told.
+func (s *socket) fail(channel, reason string) error {
+	data, _ := json.Marshal(map[string]string{"error": reason})
+	return s.conn.WriteJSON(Message{Channel: channel, Event: SubscriptionErrorEvent, Data: data})
+}
+
+// join adds a member to a presence channel, telling its subscribers if
+// they weren't already subscribed from another connection. The members
+// including the new one are returned.
+func (b *Broadcaster) join(channel, connID string, m Member) ([]Member, error) {
+	first, err := b.backend.Join(channel, connID, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if first {
+		if err := b.publishMember(channel, MemberAddedEvent, m); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.backend.Members(channel)
+}
+
+// leave removes a member from a presence channel, telling its subscribers
+// if that was their last connection
+func (b *Broadcaster) leave(channel, connID string, m Member) error {
+	last, err := b.backend.Leave(channel, connID, m)
+	if err != nil || !last {
+		return err
+	}
+
+	return b.publishMember(channel, MemberRemovedEvent, m)
+}
+
+// renew joins a connection's presence channels again so backends that
+// expire members keep them while it's open. Failures are left for the next
+// renewal to retry.
+func (b *Broadcaster) renew(connID string, members map[string]Member) {
+	for c, m := range members {
+		_, _ = b.backend.Join(c, connID, m)
+	}
+}
+
+func (b *Broadcaster) publishMember(channel, event string, m Member) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return b.backend.Publish(Message{Channel: channel, Event: event, Data: data})
+}
+
+func membersMessage(channel string, members []Member) Message {
+	data, _ := json.Marshal(members)
+	return Message{Channel: channel, Event: MembersEvent, Data: data}
+}
+
+// isOwnJoin returns whether msg announces the member joining. They're
+// already sent the members including themselves.
+func isOwnJoin(msg Message, m *Member) bool {
+	if m == nil || msg.Event != MemberAddedEvent {
+		return false
+	}
+
+	var added Member
+	return json.Unmarshal(msg.Data, &added) == nil && added.ID == m.ID
+}
+
+func newConnID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,428 @@
+package hemlock_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/broadcast"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/providers"
+	routeproviders "github.com/gschier/hemlock/support/providers"
+	. "github.com/gschier/hemlock/testutil"
+	"github.com/gschier/hemlock/websocket"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newBroadcastTestServer(t *testing.T) (*httptest.Server, *broadcast.Broadcaster) {
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+		new(testUserProviderProvider),
+		new(providers.AuthProvider),
+		new(providers.BroadcastProvider),
+	)
+
+	b := app.Make(new(broadcast.Broadcaster)).(*broadcast.Broadcaster)
+	b.Guards = []string{"token"}
+	b.Channel("orders.{id}", func(u *testUser, id string) bool {
+		return id == "42"
+	})
+	b.Channel("rooms.{id}", func(u *testUser, id string) interface{} {
+		return map[string]string{"name": "User " + u.ID}
+	})
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	return httptest.NewServer(r.Handler()), b
+}
+
+func TestBroadcast_SSE(t *testing.T) {
+	srv, b := newBroadcastTestServer(t)
+	defer srv.Close()
+
+	get := func(query string, token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/broadcasting/events?"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return res
+	}
+
+	res := get("channel=private-orders.42", "")
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "Should deny guests")
+
+	res = get("channel=private-orders.7", "secret")
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "Should deny channels the callback rejects")
+
+	res = get("channel=private-missing", "secret")
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "Should deny channels without a callback")
+
+	res = get("channel=private-orders.42&channel=presence-rooms.1", "secret")
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	br := bufio.NewReader(res.Body)
+
+	// Members are sent once subscribed
+	assert.Equal(t,
+		"event: broadcast:members\n"+
+			`data: {"channel":"presence-rooms.1","event":"broadcast:members","data":[{"id":"1","info":{"name":"User 1"}}]}`+"\n",
+		readEvent(br),
+	)
+
+	assert.NoError(t, b.To("private-orders.42").Send(broadcast.Event{Name: "shipped", Data: map[string]int{"id": 42}}))
+	assert.NoError(t, b.To("private-orders.7").Send(broadcast.Event{Name: "shipped"}))
+	assert.NoError(t, b.To("news").Send(broadcast.Event{Name: "posted"}))
+	assert.NoError(t, b.To("presence-rooms.1").Send(broadcast.Event{Name: "said", Data: "hi"}))
+
+	assert.Equal(t,
+		"event: shipped\n"+`data: {"channel":"private-orders.42","event":"shipped","data":{"id":42}}`+"\n",
+		readEvent(br),
+		"Should only get subscribed channels",
+	)
+	assert.Equal(t,
+		"event: said\n"+`data: {"channel":"presence-rooms.1","event":"said","data":"hi"}`+"\n",
+		readEvent(br),
+	)
+}
+
+func TestBroadcast_WebSocket(t *testing.T) {
+	srv, b := newBroadcastTestServer(t)
+	defer srv.Close()
+
+	// Another connection for the same user shouldn't list them twice
+	events, _ := http.NewRequest(http.MethodGet, srv.URL+"/broadcasting/events?channel=presence-rooms.1", nil)
+	events.Header.Set("Authorization", "Bearer secret")
+	res, err := http.DefaultClient.Do(events)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	readEvent(bufio.NewReader(res.Body))
+
+	conn, _, err := dialTestServer(t, srv, "/broadcasting/socket", websocket.DialOptions{
+		Header: http.Header{"Authorization": []string{"Bearer secret"}},
+	})
+	assert.NoError(t, err)
+	defer conn.Close(websocket.CloseNormal, "")
+
+	send := func(action, channel string) {
+		assert.NoError(t, conn.WriteJSON(map[string]string{"action": action, "channel": channel}))
+	}
+	read := func() broadcast.Message {
+		var msg broadcast.Message
+		assert.NoError(t, conn.ReadJSON(&msg))
+		return msg
+	}
+
+	send("subscribe", "news")
+	assert.Equal(t, broadcast.Message{Channel: "news", Event: broadcast.SubscribedEvent}, read())
+
+	send("subscribe", "private-orders.7")
+	assert.Equal(t, broadcast.Message{
+		Channel: "private-orders.7",
+		Event:   broadcast.SubscriptionErrorEvent,
+		Data:    json.RawMessage(`{"error":"Forbidden"}`),
+	}, read())
+
+	send("subscribe", "presence-rooms.1")
+	assert.Equal(t, broadcast.SubscribedEvent, read().Event)
+	assert.Equal(t, broadcast.Message{
+		Channel: "presence-rooms.1",
+		Event:   broadcast.MembersEvent,
+		Data:    json.RawMessage(`[{"id":"1","info":{"name":"User 1"}}]`),
+	}, read())
+
+	assert.NoError(t, b.To("news").Send(broadcast.Event{Name: "posted", Data: "Hello"}))
+	assert.Equal(t, broadcast.Message{Channel: "news", Event: "posted", Data: json.RawMessage(`"Hello"`)}, read())
+
+	send("unsubscribe", "news")
+	assert.Equal(t, broadcast.Message{Channel: "news", Event: broadcast.UnsubscribedEvent}, read())
+	assert.NoError(t, b.To("news").Send(broadcast.Event{Name: "posted"}))
+	assert.NoError(t, b.To("presence-rooms.1").Send(broadcast.Event{Name: "said"}))
+	assert.Equal(t, "said", read().Event, "Should stop getting unsubscribed channels")
+
+	send("unsubscribe", "presence-rooms.1")
+	assert.Equal(t, broadcast.UnsubscribedEvent, read().Event)
+	send("bogus", "")
+	assert.Equal(t, broadcast.SubscriptionErrorEvent, read().Event)
+}
+
+func TestBroadcast_MemoryPresence(t *testing.T) {
+	backend := broadcast.NewMemoryBackend()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	firsts := 0
+	for _, connID := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(connID string) {
+			defer wg.Done()
+			first, err := backend.Join("rooms.1", connID, broadcast.Member{ID: "1"})
+			assert.NoError(t, err)
+			if first {
+				mutex.Lock()
+				firsts++
+				mutex.Unlock()
+			}
+		}(connID)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, firsts, "Should be the first connection once")
+
+	first, err := backend.Join("rooms.1", "a", broadcast.Member{ID: "1"})
+	assert.NoError(t, err)
+	assert.False(t, first, "Should renew without joining again")
+
+	for _, connID := range []string{"a", "b", "c"} {
+		last, err := backend.Leave("rooms.1", connID, broadcast.Member{ID: "1"})
+		assert.NoError(t, err)
+		assert.False(t, last)
+	}
+	last, err := backend.Leave("rooms.1", "d", broadcast.Member{ID: "1"})
+	assert.NoError(t, err)
+	assert.True(t, last, "Should be the last connection")
+
+	last, err = backend.Leave("rooms.1", "d", broadcast.Member{ID: "1"})
+	assert.NoError(t, err)
+	assert.False(t, last, "Should only leave once")
+}
+
+func TestBroadcast_Redis(t *testing.T) {
+	addr := startFakeRedis(t)
+	publisher := broadcast.NewRedisBackend(addr, "pass")
+	subscriber := broadcast.NewRedisBackend(addr, "pass")
+
+	sub, err := subscriber.Subscribe("orders.42", "news")
+	assert.NoError(t, err)
+
+	assert.NoError(t, publisher.Publish(broadcast.Message{Channel: "orders.7", Event: "shipped"}))
+	assert.NoError(t, publisher.Publish(broadcast.Message{Channel: "orders.42", Event: "shipped", Data: json.RawMessage(`{"id":42}`)}))
+	assert.Equal(t,
+		broadcast.Message{Channel: "orders.42", Event: "shipped", Data: json.RawMessage(`{"id":42}`)},
+		<-sub.Messages(),
+		"Should get messages published from another connection",
+	)
+
+	assert.NoError(t, sub.Close())
+	_, ok := <-sub.Messages()
+	assert.False(t, ok, "Should close messages")
+
+	first, err := publisher.Join("rooms.1", "b", broadcast.Member{ID: "2"})
+	assert.NoError(t, err)
+	assert.True(t, first)
+	first, err = publisher.Join("rooms.1", "a", broadcast.Member{ID: "1", Info: json.RawMessage(`"first"`)})
+	assert.NoError(t, err)
+	assert.True(t, first)
+	first, err = subscriber.Join("rooms.1", "c", broadcast.Member{ID: "1", Info: json.RawMessage(`"second"`)})
+	assert.NoError(t, err)
+	assert.False(t, first, "Should not be the first connection")
+	first, err = publisher.Join("rooms.1", "a", broadcast.Member{ID: "1", Info: json.RawMessage(`"first"`)})
+	assert.NoError(t, err)
+	assert.False(t, first, "Should renew without joining again")
+	members, err := subscriber.Members("rooms.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []broadcast.Member{{ID: "1", Info: json.RawMessage(`"first"`)}, {ID: "2"}}, members)
+
+	last, err := publisher.Leave("rooms.1", "a", broadcast.Member{ID: "1"})
+	assert.NoError(t, err)
+	assert.False(t, last, "Should still be connected")
+	last, err = publisher.Leave("rooms.1", "b", broadcast.Member{ID: "2"})
+	assert.NoError(t, err)
+	assert.True(t, last)
+	members, err = publisher.Members("rooms.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []broadcast.Member{{ID: "1", Info: json.RawMessage(`"second"`)}}, members)
+
+	// Members that stop joining again expire
+	expiring := broadcast.NewRedisBackend(addr, "pass")
+	expiring.PresenceTTL = 50 * time.Millisecond
+	_, err = expiring.Join("rooms.2", "d", broadcast.Member{ID: "3"})
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	members, err = expiring.Members("rooms.2")
+	assert.NoError(t, err)
+	assert.Empty(t, members, "Should expire members")
+	first, err = expiring.Join("rooms.2", "e", broadcast.Member{ID: "3"})
+	assert.NoError(t, err)
+	assert.True(t, first, "Should join again after expiring")
+
+	_, err = broadcast.NewRedisBackend(addr, "wrong").Members("rooms.1")
+	assert.EqualError(t, err, "redis: ERR invalid password")
+}
+
+// startFakeRedis serves the few Redis commands the broadcast backend uses
+func startFakeRedis(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	var mutex sync.Mutex
+	hashes := make(map[string]map[string]string)
+	zsets := make(map[string]map[string]float64)
+	subscribers := make(map[string][]net.Conn)
+
+	bulk := func(s string) string {
+		return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+	}
+	integer := func(n int) string {
+		return ":" + strconv.Itoa(n) + "\r\n"
+	}
+	score := func(s string) float64 {
+		f, _ := strconv.ParseFloat(s, 64)
+		if s == "-inf" {
+			f = math.Inf(-1)
+		}
+		return f
+	}
+
+	// run runs a command that doesn't depend on the connection
+	run := func(args []string) string {
+		switch args[0] {
+		case "PUBLISH":
+			for _, c := range subscribers[args[1]] {
+				c.Write([]byte("*3\r\n" + bulk("message") + bulk(args[1]) + bulk(args[2])))
+			}
+			return integer(len(subscribers[args[1]]))
+		case "HSET":
+			if hashes[args[1]] == nil {
+				hashes[args[1]] = make(map[string]string)
+			}
+			hashes[args[1]][args[2]] = args[3]
+			return integer(1)
+		case "HDEL":
+			n := 0
+			for _, field := range args[2:] {
+				if _, ok := hashes[args[1]][field]; ok {
+					delete(hashes[args[1]], field)
+					n++
+				}
+			}
+			return integer(n)
+		case "HGETALL":
+			reply := "*" + strconv.Itoa(len(hashes[args[1]])*2) + "\r\n"
+			for k, v := range hashes[args[1]] {
+				reply += bulk(k) + bulk(v)
+			}
+			return reply
+		case "ZADD":
+			if zsets[args[1]] == nil {
+				zsets[args[1]] = make(map[string]float64)
+			}
+			_, exists := zsets[args[1]][args[3]]
+			zsets[args[1]][args[3]] = score(args[2])
+			if exists {
+				return integer(0)
+			}
+			return integer(1)
+		case "ZREM":
+			_, exists := zsets[args[1]][args[2]]
+			delete(zsets[args[1]], args[2])
+			if exists {
+				return integer(1)
+			}
+			return integer(0)
+		case "ZCARD":
+			return integer(len(zsets[args[1]]))
+		case "ZREMRANGEBYSCORE":
+			n := 0
+			for member, s := range zsets[args[1]] {
+				if s >= score(args[2]) && s <= score(args[3]) {
+					delete(zsets[args[1]], member)
+					n++
+				}
+			}
+			return integer(n)
+		case "ZRANGE":
+			reply := "*" + strconv.Itoa(len(zsets[args[1]])) + "\r\n"
+			for member := range zsets[args[1]] {
+				reply += bulk(member)
+			}
+			return reply
+		case "PEXPIRE":
+			return integer(1)
+		}
+		return "-ERR unknown command\r\n"
+	}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		authed := false
+		var queued [][]string
+		inMulti := false
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, n)
+			for i := range args {
+				br.ReadString('\n')
+				arg, _ := br.ReadString('\n')
+				args[i] = strings.TrimSuffix(arg, "\r\n")
+			}
+
+			mutex.Lock()
+			var reply string
+			switch {
+			case args[0] == "AUTH" && args[1] == "pass":
+				authed = true
+				reply = "+OK\r\n"
+			case !authed:
+				reply = "-ERR invalid password\r\n"
+			case args[0] == "MULTI":
+				inMulti, queued = true, nil
+				reply = "+OK\r\n"
+			case args[0] == "DISCARD":
+				inMulti, queued = false, nil
+				reply = "+OK\r\n"
+			case args[0] == "EXEC":
+				reply = "*" + strconv.Itoa(len(queued)) + "\r\n"
+				for _, q := range queued {
+					reply += run(q)
+				}
+				inMulti, queued = false, nil
+			case inMulti:
+				queued = append(queued, args)
+				reply = "+QUEUED\r\n"
+			case args[0] == "SUBSCRIBE":
+				for i, ch := range args[1:] {
+					subscribers[ch] = append(subscribers[ch], conn)
+					reply += "*3\r\n" + bulk("subscribe") + bulk(ch) + integer(i+1)
+				}
+			default:
+				reply = run(args)
+			}
+			conn.Write([]byte(reply))
+			mutex.Unlock()
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+var _ = hemlock.Config{}
@@ -1,5 +1,7 @@
 package hemlock
 
+import "time"
+
 // Config contains the static configuration for a Hemlock application.  There is
 // an optional 'Extra' field for storing configuration not directly related to
 // the core Hemlock functionality.
@@ -7,12 +9,16 @@ type Config struct {
 	Name               string
 	Env                string
 	URL                string
+	Key                string // Secret used to sign cookies and URLs
 	TemplatesDirectory string
 	PublicDirectory    string
 	PublicPrefix       string
 	Database           *DatabaseConfig
 	Sessions           *SessionConfig
+	Hashing            *HashingConfig
+	JWT                *JWTConfig
 	HTTP               *HTTPConfig
+	Broadcasting       *BroadcastConfig
 	Extra              []interface{}
 }
 
@@ -20,6 +26,30 @@ type Config struct {
 type HTTPConfig struct {
 	Host string
 	Port string
+
+	// MaxBodySize is the default maximum request body size in bytes. Zero
+	// means unlimited.
+	MaxBodySize int64
+
+	// MaxFileSize is the default maximum size in bytes of a single uploaded
+	// file. Zero means unlimited.
+	MaxFileSize int64
+
+	// UploadMemory is the number of bytes of a multipart request that are
+	// kept in memory before the rest is spooled to temporary files.
+	UploadMemory int64
+
+	// JSONEnvelope is the key successful JSON responses are wrapped in, like
+	// "data" for {"data": ...}. Empty means no envelope.
+	JSONEnvelope string
+
+	// JSONPCallback is the query parameter naming a JSONP callback for JSON
+	// responses. JSONP is disabled if empty.
+	JSONPCallback string
+
+	// SSEHeartbeat is how often comments are sent to keep Server-Sent Event
+	// streams open. Defaults to 15 seconds. Negative disables heartbeats.
+	SSEHeartbeat time.Duration
 }
 
 // DatabaseConfig contains database settings.
@@ -43,9 +73,61 @@ type DatabaseConnectionConfig struct {
 	SSLMode   bool
 }
 
-// SessionConfig contains database settings.
+// HashingConfig contains password hashing settings.
+type HashingConfig struct {
+	Driver     string // 'bcrypt' (default) or 'argon2id'
+	BcryptCost int    // Defaults to 12
+
+	Argon2Memory  uint32 // Memory in KiB. Defaults to 64 MiB
+	Argon2Time    uint32 // Number of passes. Defaults to 3
+	Argon2Threads uint8  // Degree of parallelism. Defaults to 2
+}
+
+// JWTConfig contains settings for issuing and verifying JSON Web Tokens.
+type JWTConfig struct {
+	Issuer   string        // Required 'iss' claim, if set
+	Audience []string      // Accepted 'aud' claims, if set
+	TTL      time.Duration // Lifetime of issued tokens. Defaults to 1 hour
+	Leeway   time.Duration // Allowed clock skew. Defaults to 1 minute
+
+	// Keys used to sign and verify tokens. The first key with private
+	// material signs new tokens. If empty, an HS256 key derived from the
+	// app key is used.
+	Keys []JWTKey
+}
+
+// JWTKey is a key used to sign or verify JSON Web Tokens.
+type JWTKey struct {
+	ID        string // Matched against the 'kid' header
+	Algorithm string // 'HS256', 'RS256' or 'EdDSA'
+	Secret    []byte // Shared secret for HS256. At least 32 bytes
+	Private   []byte // PEM encoded PKCS #8 private key for RS256 and EdDSA
+	Public    []byte // PEM encoded PKIX public key for RS256 and EdDSA
+}
+
+// BroadcastConfig contains settings for broadcasting events to clients.
+type BroadcastConfig struct {
+	Driver        string // 'memory' (default) or 'redis' to share events between servers
+	RedisAddr     string // Defaults to 'localhost:6379'
+	RedisPassword string
+
+	// Guards are the auth guards tried in order to find the user
+	// subscribing to private and presence channels. Defaults to the default
+	// guard.
+	Guards []string
+
+	// Path the subscription endpoints are served under. Defaults to
+	// '/broadcasting'.
+	Path string
+}
+
+// SessionConfig contains session settings.
 type SessionConfig struct {
-	Default     string // 'postgres'
-	Migrations  string // 'migrations'
-	Connections []DatabaseConnectionConfig
+	Cookie   string        // 'hemlock_session'
+	Domain   string        // Defaults to the request host
+	Lifetime time.Duration // Defaults to 2 hours
+	Secure   bool          // Only send the cookie over HTTPS
+
+	// DisableCSRF turns off CSRF verification for state-changing requests
+	DisableCSRF bool
 }
@@ -0,0 +1,90 @@
+package hemlock
+
+import (
+	"errors"
+	"net/http"
+)
+
+// HTTPError is an error to respond to the client with. Message is safe to
+// show publicly while Cause, if set, is only reported.
+//
+// For example:
+//
+//	if post == nil {
+//		return res.Error(hemlock.NotFound().WithMessage("That post was deleted"))
+//	}
+type HTTPError struct {
+	Status  int
+	Message string
+	Cause   error
+}
+
+// NewHTTPError returns an HTTPError with a status and public message. If
+// message is empty, the status text is used.
+func NewHTTPError(status int, message string) *HTTPError {
+	return &HTTPError{Status: status, Message: message}
+}
+
+// Shortcuts for common errors using the status text as their message
+
+func BadRequest() *HTTPError          { return NewHTTPError(http.StatusBadRequest, "") }
+func Unauthorized() *HTTPError        { return NewHTTPError(http.StatusUnauthorized, "") }
+func Forbidden() *HTTPError           { return NewHTTPError(http.StatusForbidden, "") }
+func NotFound() *HTTPError            { return NewHTTPError(http.StatusNotFound, "") }
+func MethodNotAllowed() *HTTPError    { return NewHTTPError(http.StatusMethodNotAllowed, "") }
+func Conflict() *HTTPError            { return NewHTTPError(http.StatusConflict, "") }
+func UnprocessableEntity() *HTTPError { return NewHTTPError(http.StatusUnprocessableEntity, "") }
+func TooManyRequests() *HTTPError     { return NewHTTPError(http.StatusTooManyRequests, "") }
+
+// InternalServerError returns a 500 HTTPError caused by err
+func InternalServerError(err error) *HTTPError {
+	return &HTTPError{Status: http.StatusInternalServerError, Cause: err}
+}
+
+// AsHTTPError returns err if it is or wraps an HTTPError. Other errors are
+// wrapped in a 500 so their details aren't shown to the client.
+func AsHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return InternalServerError(err)
+}
+
+func (e *HTTPError) Error() string {
+	if e.Cause != nil {
+		return e.PublicMessage() + ": " + e.Cause.Error()
+	}
+	return e.PublicMessage()
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// PublicMessage returns the message to show the client
+func (e *HTTPError) PublicMessage() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	if text := http.StatusText(e.Status); text != "" {
+		return text
+	}
+
+	return "Error"
+}
+
+// WithMessage returns a copy of the error with a different public message
+func (e *HTTPError) WithMessage(message string) *HTTPError {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+// Wrap returns a copy of the error with a cause to report
+func (e *HTTPError) Wrap(cause error) *HTTPError {
+	c := *e
+	c.Cause = cause
+	return &c
+}
@@ -0,0 +1,114 @@
+package exceptions
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/templates"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Handler is the default ExceptionHandler. Server errors are logged and
+// errors are rendered as JSON for API requests, problem+json for clients
+// that accept it, the errors/<status>.html view if there is one, or plain
+// text otherwise.
+//
+// Apps can embed it to customize part of its behaviour:
+//
+//	type ExceptionHandler struct {
+//		*exceptions.Handler
+//	}
+//
+//	func (h *ExceptionHandler) Report(req interfaces.Request, err error) {
+//		sentry.CaptureException(err)
+//		h.Handler.Report(req, err)
+//	}
+type Handler struct {
+	// Logger receives reported errors. Defaults to stdout.
+	Logger *log.Logger
+
+	// Layout is the layout error views are rendered in
+	Layout string
+
+	// ReportAll reports client errors as well as server errors
+	ReportAll bool
+
+	app *hemlock.Application
+}
+
+func New(app *hemlock.Application) *Handler {
+	return &Handler{
+		Logger: log.New(os.Stdout, "[exceptions] ", log.LstdFlags),
+		app:    app,
+	}
+}
+
+type messageBody struct {
+	Message string `json:"message"`
+}
+
+// problem is an RFC 7807 problem details object
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func (h *Handler) Report(req interfaces.Request, err error) {
+	httpErr := hemlock.AsHTTPError(err)
+	if httpErr.Status < http.StatusInternalServerError && !h.ReportAll {
+		return
+	}
+
+	h.Logger.Printf("%s %s: %v", req.Method(), req.Path(), err)
+}
+
+func (h *Handler) Render(req interfaces.Request, res interfaces.Response, err error) interfaces.Result {
+	httpErr := hemlock.AsHTTPError(err)
+	res.Status(httpErr.Status)
+
+	if strings.Contains(req.Header("Accept"), "application/problem+json") {
+		title := http.StatusText(httpErr.Status)
+		if title == "" {
+			title = httpErr.PublicMessage()
+		}
+
+		return res.
+			Header("Content-Type", "application/problem+json").
+			Data(&problem{
+				Type:     "about:blank",
+				Title:    title,
+				Status:   httpErr.Status,
+				Detail:   httpErr.PublicMessage(),
+				Instance: req.Path(),
+			})
+	}
+
+	if req.WantsJSON() {
+		return res.JSON(httpErr.Status, &messageBody{Message: httpErr.PublicMessage()})
+	}
+
+	view := "errors/" + strconv.Itoa(httpErr.Status) + ".html"
+	if h.hasView(view) {
+		return res.View(view, h.Layout, map[string]interface{}{
+			"Status":  httpErr.Status,
+			"Message": httpErr.PublicMessage(),
+		})
+	}
+
+	return res.Header("Content-Type", "text/plain; charset=utf-8").Data(httpErr.PublicMessage())
+}
+
+func (h *Handler) hasView(name string) bool {
+	if h.app == nil || !h.app.Has(new(templates.Renderer)) {
+		return false
+	}
+
+	renderer := h.app.Make(new(templates.Renderer)).(*templates.Renderer)
+	return renderer.HasView(name, h.Layout)
+}
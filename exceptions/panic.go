@@ -0,0 +1,50 @@
+package exceptions
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// PanicError is a panic recovered while handling a request
+type PanicError struct {
+	Value  interface{}
+	Frames []runtime.Frame
+}
+
+// NewPanicError wraps a recovered value along with the stack of the
+// goroutine that panicked. It must be called from the deferred function
+// that recovered.
+func NewPanicError(v interface{}) *PanicError {
+	return &PanicError{Value: v, Frames: Stack(2)}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Stack returns the calling goroutine's stack, skipping the given number
+// of callers and any frames inside the Go runtime
+func Stack(skip int) []runtime.Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, frame)
+		}
+		if !more {
+			break
+		}
+	}
+
+	return stack
+}
@@ -0,0 +1,238 @@
+package gate
+
+import (
+	"context"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/auth"
+	"github.com/gschier/hemlock/interfaces"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type contextKey string
+
+const gateContextKey = contextKey("gate")
+
+var boolType = reflect.TypeOf(true)
+
+// Gate holds the abilities and policies defined by the application
+type Gate struct {
+	abilities map[string]reflect.Value
+	policies  map[reflect.Type]reflect.Value
+	mutex     sync.RWMutex
+}
+
+func New() *Gate {
+	return &Gate{
+		abilities: make(map[string]reflect.Value),
+		policies:  make(map[reflect.Type]reflect.Value),
+	}
+}
+
+// Define registers an ability. The callback receives the user followed by
+// any arguments passed when checking the ability and returns whether the
+// user is allowed.
+//
+// For example:
+//
+//	g.Define("edit-post", func(u *models.User, p *models.Post) bool {
+//		return p.AuthorID == u.ID
+//	})
+func (g *Gate) Define(ability string, callback interface{}) {
+	fn := reflect.ValueOf(callback)
+	if fn.Kind() != reflect.Func || fn.Type().NumIn() == 0 ||
+		fn.Type().NumOut() != 1 || fn.Type().Out(0) != boolType {
+		panic("Ability " + ability + " must be a func taking a user and returning a bool")
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.abilities[ability] = fn
+}
+
+// Policy registers a policy for a type of resource. When an ability is
+// checked with the resource as its first argument, the policy method named
+// after the ability is called instead of any defined ability.
+//
+// For example:
+//
+//	g.Policy(&models.Post{}, &PostPolicy{})
+//
+//	// Calls PostPolicy.Update(user, post)
+//	g.Allows(user, "update", post)
+func (g *Gate) Policy(resource interface{}, policy interface{}) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.policies[reflect.TypeOf(resource)] = reflect.ValueOf(policy)
+}
+
+// Allows returns whether the user may perform the ability. Guests (a nil
+// user) are always denied.
+func (g *Gate) Allows(user auth.Authenticatable, ability string, args ...interface{}) bool {
+	if isNil(user) {
+		return false
+	}
+
+	fn, ok := g.policyMethod(ability, args)
+	if !ok {
+		g.mutex.RLock()
+		fn, ok = g.abilities[ability]
+		g.mutex.RUnlock()
+	}
+
+	if !ok {
+		return false
+	}
+
+	return call(fn, append([]interface{}{user}, args...))
+}
+
+// Denies is the opposite of Allows
+func (g *Gate) Denies(user auth.Authenticatable, ability string, args ...interface{}) bool {
+	return !g.Allows(user, ability, args...)
+}
+
+// ForUser returns a UserGate that checks abilities for a single user
+func (g *Gate) ForUser(user auth.Authenticatable) *UserGate {
+	return &UserGate{gate: g, user: user}
+}
+
+// Middleware makes the Gate available to the Can middleware
+func (g *Gate) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), gateContextKey, g)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (g *Gate) policyMethod(ability string, args []interface{}) (reflect.Value, bool) {
+	if len(args) == 0 {
+		return reflect.Value{}, false
+	}
+
+	g.mutex.RLock()
+	policy, ok := g.policies[reflect.TypeOf(args[0])]
+	g.mutex.RUnlock()
+	if !ok {
+		return reflect.Value{}, false
+	}
+
+	m := policy.MethodByName(methodName(ability))
+	if !m.IsValid() || m.Type().NumOut() != 1 || m.Type().Out(0) != boolType {
+		return reflect.Value{}, false
+	}
+
+	return m, true
+}
+
+// UserGate checks abilities for a single user
+type UserGate struct {
+	gate *Gate
+	user auth.Authenticatable
+}
+
+func (g *UserGate) Allows(ability string, args ...interface{}) bool {
+	return g.gate.Allows(g.user, ability, args...)
+}
+
+func (g *UserGate) Denies(ability string, args ...interface{}) bool {
+	return g.gate.Denies(g.user, ability, args...)
+}
+
+// Authorize returns a 403 Result if the user may not perform the ability,
+// or nil if they may.
+//
+// For example:
+//
+//	if denied := g.Authorize(res, "edit-post", post); denied != nil {
+//		return denied
+//	}
+func (g *UserGate) Authorize(res interfaces.Response, ability string, args ...interface{}) interfaces.Result {
+	if g.Allows(ability, args...) {
+		return nil
+	}
+	return forbidden(res)
+}
+
+// Can rejects requests from users that may not perform the ability
+//
+// For example:
+//
+//	admin := r.With(auth.Required(), gate.Can("view-admin"))
+func Can(ability string, args ...interface{}) interfaces.Middleware {
+	return func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+		g, _ := req.Context().Value(gateContextKey).(*Gate)
+		if g == nil {
+			panic("gate.Can used without the GateProvider")
+		}
+
+		var user auth.Authenticatable
+		if a := auth.Current(req); a != nil {
+			user = a.User()
+		}
+
+		if g.Denies(user, ability, args...) {
+			return forbidden(res)
+		}
+
+		return next(req, res)
+	}
+}
+
+func forbidden(res interfaces.Response) interfaces.Result {
+	return res.Error(hemlock.Forbidden())
+}
+
+// call calls fn with args if their types line up, returning false otherwise
+func call(fn reflect.Value, args []interface{}) bool {
+	fnType := fn.Type()
+	if fnType.NumIn() != len(args) {
+		return false
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		argType := fnType.In(i)
+		if arg == nil {
+			switch argType.Kind() {
+			case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+				in[i] = reflect.Zero(argType)
+				continue
+			}
+			return false
+		}
+
+		v := reflect.ValueOf(arg)
+		if !v.Type().AssignableTo(argType) {
+			return false
+		}
+		in[i] = v
+	}
+
+	return fn.Call(in)[0].Bool()
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// methodName converts an ability like "view-any" to a method name like
+// "ViewAny"
+func methodName(ability string) string {
+	parts := strings.FieldsFunc(ability, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.' || r == ' '
+	})
+
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package hemlock_test
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/providers"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func newTestHasher(config *hemlock.HashingConfig) interfaces.Hasher {
+	app := hemlock.NewApplication(&hemlock.Config{Hashing: config}, []hemlock.Provider{
+		new(providers.HashingProvider),
+	})
+
+	var h interfaces.Hasher
+	app.Resolve(&h)
+	return h
+}
+
+func TestHasher_Upgrade(t *testing.T) {
+	bcrypt := newTestHasher(&hemlock.HashingConfig{BcryptCost: 4})
+	argon2 := newTestHasher(&hemlock.HashingConfig{Driver: "argon2id", Argon2Memory: 1024, Argon2Time: 1})
+
+	oldHash, err := bcrypt.Hash("password")
+	assert.NoError(t, err)
+	assert.True(t, bcrypt.Check("password", oldHash), "Should match")
+	assert.False(t, bcrypt.Check("nope", oldHash), "Should not match")
+	assert.False(t, bcrypt.NeedsRehash(oldHash), "Should not need rehash")
+
+	assert.True(t, argon2.Check("password", oldHash), "Should check hashes from other drivers")
+	assert.True(t, argon2.NeedsRehash(oldHash), "Should need rehash after driver change")
+
+	newHash, err := argon2.Hash("password")
+	assert.NoError(t, err)
+	assert.True(t, argon2.Check("password", newHash), "Should match")
+	assert.False(t, argon2.Check("nope", newHash), "Should not match")
+	assert.False(t, argon2.NeedsRehash(newHash), "Should not need rehash")
+
+	stronger := newTestHasher(&hemlock.HashingConfig{Driver: "argon2id", Argon2Memory: 2048, Argon2Time: 1})
+	assert.True(t, stronger.NeedsRehash(newHash), "Should need rehash after raising cost")
+}
+
+func TestHasher_InvalidArgon2(t *testing.T) {
+	argon2 := newTestHasher(&hemlock.HashingConfig{Driver: "argon2id", Argon2Memory: 1024, Argon2Time: 1})
+
+	hash, err := argon2.Hash("password")
+	assert.NoError(t, err)
+	parts := strings.Split(hash, "$")
+	salt, key := parts[4], parts[5]
+
+	for _, params := range []string{"m=1024,t=0,p=1", "m=1024,t=1,p=0", "m=1024,t=1000,p=1", "m=4194304,t=1,p=1"} {
+		bad := "$argon2id$v=19$" + params + "$" + salt + "$" + key
+		assert.False(t, argon2.Check("password", bad), "Should reject "+params)
+		assert.True(t, argon2.NeedsRehash(bad), "Should rehash "+params)
+	}
+
+	assert.False(t, argon2.Check("anything", "$argon2id$v=19$m=1024,t=1,p=1$"+salt+"$"), "Should reject an empty key")
+}
@@ -0,0 +1,13 @@
+package hemlock
+
+// Input marks a struct as request input when embedded in it. Route
+// callback arguments using `route`, `query` or `form` tags are bound from
+// the request already, but structs only read from a JSON body need it.
+//
+// For example:
+//
+//	type CreatePost struct {
+//		hemlock.Input
+//		Title string `json:"title" validate:"required"`
+//	}
+type Input struct{}
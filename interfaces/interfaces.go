@@ -2,9 +2,12 @@ package interfaces
 
 import (
 	"context"
+	"github.com/gschier/hemlock/openapi"
+	"github.com/gschier/hemlock/websocket"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // Container is used to bind objects to the application
@@ -40,6 +43,13 @@ type Request interface {
 	// Host returns the host of the request.
 	Host() string
 
+	// Param returns a route parameter by name
+	Param(name string) string
+
+	// ParamInt returns a route parameter by name converted to an int, or 0
+	// if it isn't a valid int
+	ParamInt(name string) int
+
 	// Input grabs input from the query string by name
 	Query(name string) string
 
@@ -49,11 +59,25 @@ type Request interface {
 	// Post grabs input from the post data by name
 	Post(name string) string
 
+	// Bind populates the struct pointed to by v from the route parameters,
+	// query string and body of the request using `route`, `query`, `form`
+	// and `json` struct tags. The body is decoded based on its Content-Type.
+	// Errors are *hemlock.HTTPErrors with the status to respond with.
+	Bind(v interface{}) error
+
 	// Cookie grabs input from cookies by name
 	Cookie(name string) string
 
-	// File grabs the file
-	File(name string) io.Reader
+	// File returns the first file uploaded under the named form field, or nil
+	// if there isn't one
+	File(name string) UploadedFile
+
+	// Files returns every file uploaded under the named form field
+	Files(name string) []UploadedFile
+
+	// WantsJSON returns whether the client expects a JSON response rather
+	// than a page, like an API client or a script using XMLHttpRequest
+	WantsJSON() bool
 
 	// Context returns the context.Context of the current request
 	Context() context.Context
@@ -62,6 +86,76 @@ type Request interface {
 	WithContext(ctx context.Context) Request
 }
 
+// UploadedFile is a file received as part of a multipart request
+type UploadedFile interface {
+	// Filename returns the name of the file provided by the client
+	Filename() string
+
+	// Size returns the size of the file in bytes
+	Size() int64
+
+	// ContentType returns the content type detected from the file contents
+	ContentType() string
+
+	// Open opens the file for reading
+	Open() (io.ReadCloser, error)
+
+	// SaveTo writes the file to the provided path
+	SaveTo(path string) error
+
+	// Store writes the file to a disk under a generated name and returns
+	// the name it was stored as
+	Store(disk Disk) (string, error)
+}
+
+// Disk is a storage location that files can be written to
+type Disk interface {
+	// Put writes the contents of r to the file with the provided name
+	Put(name string, r io.Reader) error
+}
+
+// Session stores data for a client across requests
+type Session interface {
+	// ID returns the session identifier
+	ID() string
+
+	// Get returns a session value, or an empty string if it isn't set
+	Get(key string) string
+
+	// Has returns whether the key is set
+	Has(key string) bool
+
+	// Put sets a session value
+	Put(key, value string)
+
+	// Forget removes a session value
+	Forget(key string)
+
+	// Flash sets a value that is only available during the next request
+	Flash(key, value string)
+
+	// Regenerate assigns the session a new ID. This should be called
+	// whenever the user's privileges change to prevent session fixation.
+	Regenerate()
+
+	// Invalidate removes all data from the session and regenerates its ID
+	Invalidate()
+}
+
+// Hasher hashes and verifies passwords
+type Hasher interface {
+	// Hash returns a hash of the password using the configured algorithm
+	Hash(password string) (string, error)
+
+	// Check returns whether the password matches the hash. Hashes from any
+	// supported algorithm can be checked.
+	Check(password, hash string) bool
+
+	// NeedsRehash returns whether the hash was created with a different
+	// algorithm or weaker parameters than are currently configured
+	NeedsRehash(hash string) bool
+}
+
 // Response is be used to send data to the client
 type Response interface {
 	// Cookie sets an HTTP cookie on the response
@@ -76,10 +170,43 @@ type Response interface {
 	// Data responds with data provided
 	//
 	// Most types will converted to a string representation except structs,
-	// which will be serialized to JSON.
+	// maps and slices, which will be serialized to JSON.
 	Data(data interface{}) Result
 
-	// Error sends the default 500 response and logs the error message
+	// JSON responds with v serialized to JSON. Output is indented in
+	// development, successful responses are wrapped in the configured
+	// envelope and JSONP is used if enabled and requested.
+	JSON(status int, v interface{}) Result
+
+	// JSONStream responds with a JSON array of the items returned by next,
+	// encoding each as it's produced. next returns false when there are no
+	// more items. If it returns an error, the response is cut off so the
+	// client can't mistake it for a complete array.
+	JSONStream(status int, next func() (interface{}, bool, error)) Result
+
+	// Stream responds with Server-Sent Events written by fn. Comments are
+	// sent as heartbeats while it runs, and the request context is
+	// cancelled when the client goes away.
+	//
+	// For example:
+	//
+	//     return res.Stream(func(w EventWriter) error {
+	//         for msg := range messagesAfter(w.LastEventID()) {
+	//             if err := w.Send(Event{ID: msg.ID, Data: msg}); err != nil {
+	//                 return err
+	//             }
+	//         }
+	//         return nil
+	//     })
+	Stream(fn func(w EventWriter) error) Result
+
+	// SSE streams events from the channel until it's closed or the client
+	// goes away
+	SSE(events <-chan Event) Result
+
+	// Error reports the error and responds with it using the app's
+	// ExceptionHandler. Use a *hemlock.HTTPError to control the status and
+	// message, otherwise a 500 is sent without any details.
 	Error(error) Result
 
 	// Sprintf builds a response using `fmt.Sprintf`
@@ -88,6 +215,15 @@ type Response interface {
 	// View renders a view for the response with a provided layout and data
 	View(name, layout string, data map[string]interface{}) Result
 
+	// Negotiate responds with data encoded in the format the request's
+	// Accept header prefers, or 406 if none of the registered encoders can
+	// satisfy it
+	Negotiate(data interface{}) Result
+
+	// NegotiateView is the same as Negotiate but renders the view for
+	// clients that prefer HTML. The data is passed to the view as is.
+	NegotiateView(name, layout string, data interface{}) Result
+
 	// Redirect redirects the client to a URL
 	Redirect(uri string, code int) Result
 
@@ -98,16 +234,125 @@ type Response interface {
 	End() Result
 }
 
+// Result is returned by callbacks and middleware once they've responded.
+// Middleware receive the downstream Result from next and can change its
+// headers until the response is flushed, which happens when the middleware
+// chain returns, when the body is flushed or when it grows too large to
+// hold back.
 type Result interface {
+	// StatusCode returns the status the response was sent with
+	StatusCode() int
+
+	// Header returns the response headers
+	Header() http.Header
+
+	// Err returns the error the response was created for, if any
+	Err() error
+
 	Data(data interface{}) Result
+	JSON(status int, v interface{}) Result
+	JSONStream(status int, next func() (interface{}, bool, error)) Result
+	Stream(fn func(w EventWriter) error) Result
+	SSE(events <-chan Event) Result
 	Error(error) Result
 	Sprintf(format string, a ...interface{}) Result
 	View(name, layout string, data map[string]interface{}) Result
+	Negotiate(data interface{}) Result
+	NegotiateView(name, layout string, data interface{}) Result
 	Redirect(uri string, code int) Result
 	RedirectRoute(name string, params map[string]string, code int) Result
 }
 
+// ExceptionHandler reports errors and turns them into responses. The
+// application's handler is resolved from the container whenever a callback
+// responds with an error.
+type ExceptionHandler interface {
+	// Report records the error, like by logging it
+	Report(req Request, err error)
+
+	// Render responds to the request with the error
+	Render(req Request, res Response, err error) Result
+}
+
+// Event is a Server-Sent Event
+type Event struct {
+	// ID is sent back in the Last-Event-ID header when the client
+	// reconnects
+	ID string
+
+	// Event is the event type. Empty means "message".
+	Event string
+
+	// Data is sent as is if it's a string or []byte, and as JSON otherwise
+	Data interface{}
+
+	// Retry tells the client how long to wait before reconnecting
+	Retry time.Duration
+}
+
+// EventWriter sends Server-Sent Events. Each write is flushed to the
+// client right away.
+type EventWriter interface {
+	// Send writes an event. It fails once the client has gone away.
+	Send(event Event) error
+
+	// Retry tells the client how long to wait before reconnecting
+	Retry(d time.Duration) error
+
+	// Comment writes a comment, which clients ignore
+	Comment(text string) error
+
+	// LastEventID returns the ID of the last event the client saw before
+	// reconnecting, so the stream can resume after it
+	LastEventID() string
+
+	// Context is cancelled when the client goes away
+	Context() context.Context
+}
+
+// WebSocketConn is a WebSocket connection, injected into WebSocket route
+// callbacks. Message types and close codes are in the websocket package.
+type WebSocketConn interface {
+	// ReadMessage waits for the next text or binary message. Once the
+	// connection closes, it returns a *websocket.CloseError.
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+
+	// Ping sends a ping. Pings from the peer are answered automatically.
+	Ping(data []byte) error
+	OnPing(fn func(data []byte))
+	OnPong(fn func(data []byte))
+
+	// Close sends a close frame and closes the connection
+	Close(code int, reason string) error
+
+	SetReadLimit(limit int64)
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	Subprotocol() string
+}
+
+// Encoder serializes response data for content negotiation
+type Encoder interface {
+	// MediaType returns the media type produced, like "application/json"
+	MediaType() string
+
+	// CanEncode returns whether the encoder supports the data
+	CanEncode(data interface{}) bool
+
+	// Encode writes the encoded data
+	Encode(w io.Writer, data interface{}) error
+}
+
 // Router provides the ability to define HTTP routes
+//
+// Callbacks ask for what they need as arguments. Structs are bound from the
+// request with Request.Bind when they have `route`, `query` or `form` tags,
+// or embed hemlock.Input. Structs with only `json` tags must embed
+// hemlock.Input to be read from a JSON body, since otherwise they're
+// resolved from the container like services and models.
 type Router interface {
 	Callback(callback Callback) Route
 	Get(uri string, callback Callback) Route
@@ -124,6 +369,25 @@ type Router interface {
 	// Redirect creates a static redirect route for the provided URI
 	Redirect(uri, to string, code int) Route
 
+	// WebSocket adds a route that upgrades requests to WebSockets. The
+	// middleware runs on the upgrade request, then the callback is called
+	// with a WebSocketConn it can ask for like any other argument. The
+	// connection is closed when the callback returns, with
+	// CloseInternalError if it returns an error or panics.
+	//
+	// For example:
+	//
+	//     r.WebSocket("/echo", func(conn WebSocketConn) error {
+	//         for {
+	//             t, data, err := conn.ReadMessage()
+	//             if err != nil {
+	//                 return nil
+	//             }
+	//             conn.WriteMessage(t, data)
+	//         }
+	//     })
+	WebSocket(uri string, callback Callback, options ...websocket.Options) Route
+
 	// View creates a route
 	View(uri, view, layout string, data map[string]interface{}) Route
 
@@ -139,30 +403,177 @@ type Router interface {
 	//     r.Host("{subdomain:[a-z]+}.domain.com", func (r *hemlock.Router) { ... }))
 	Host(uri string) Route
 
-	// With returns a new Router with middleware applied
+	// With returns a new Route with middleware applied
 	//
 	// For example:
 	//
-	//     admin := r.With(adminMiddleware)
-	//	   admin.Get("/admin", adminDashboard)
+	//     r.With(adminMiddleware).Get("/admin", adminDashboard)
 	With(...Middleware) Route
 	WithG(...func(http.Handler) http.Handler) Route
 
+	// Middleware returns a new Route with middleware applied. Middleware can
+	// be hemlock middleware, native middleware or the name of an alias.
+	//
+	// For example:
+	//
+	//     r.Middleware("auth").Group(func(r interfaces.Router) { ... })
+	Middleware(...interface{}) Route
+
+	// Use adds middleware for every route on the router
 	Use(...Middleware)
 	UseG(...func(http.Handler) http.Handler)
 
+	// Resource adds routes for the conventional methods a controller has:
+	//
+	//     GET       /photos              Index    photos.index
+	//     GET       /photos/create       Create   photos.create
+	//     POST      /photos              Store    photos.store
+	//     GET       /photos/{photo}      Show     photos.show
+	//     GET       /photos/{photo}/edit Edit     photos.edit
+	//     PUT/PATCH /photos/{photo}      Update   photos.update
+	//     DELETE    /photos/{photo}      Destroy  photos.destroy
+	//
+	// Methods are called like any other callback, and may have pointer
+	// receivers even if the controller is passed by value. Routes are only
+	// added for the methods the controller has, and it panics if that's
+	// none of them. Nested resources are named with dots, like
+	// "posts.comments" for /posts/{post}/comments.
+	Resource(name string, controller interface{}, options ...ResourceOptions)
+
+	// APIResource is the same as Resource without the Create and Edit
+	// routes that show forms
+	APIResource(name string, controller interface{}, options ...ResourceOptions)
+
+	// Model resolves callback arguments from a route parameter using a
+	// lookup function. Callbacks asking for the type the lookup returns get
+	// the result, or a 404 if it's nil. The lookup gets the parameter as a
+	// string and anything else from the container. It's only called for
+	// callbacks asking for the type, and each type can only have one
+	// lookup. Routes can use other parameters with Route.ModelParams.
+	//
+	// For example:
+	//
+	//     r.Model("user", func(id string, db *DB) (*User, error) {
+	//         return db.FindUser(id)
+	//     })
+	//     r.Get("/users/{user}", func(user *User, res Response) Result { ... })
+	Model(param string, lookup interface{})
+
+	// Alias names middleware so routes can refer to it. Giving several
+	// middleware, including other aliases, defines a group.
+	//
+	// For example:
+	//
+	//     r.Alias("auth", auth.Required())
+	//     r.Alias("api", "auth", rateLimit)
+	Alias(name string, m ...interface{})
+
+	// MiddlewarePriority sets the order that aliased middleware run in when
+	// a route has several of them, regardless of the order they were added.
+	// Middleware is only sorted among that added in the same place, so
+	// global middleware still runs before a group's, then the route's.
+	MiddlewarePriority(names ...string)
+
+	// NotFound sets the callback for requests that don't match a route. It
+	// runs after the app's global middleware and responds with a 404 unless
+	// it sets another status.
+	NotFound(callback Callback)
+
+	// MethodNotAllowed sets the callback for requests that match a route's
+	// URL but not its methods. It runs after the app's global middleware
+	// and responds with a 405 unless it sets another status.
+	MethodNotAllowed(callback Callback)
+
+	// SignedRoute is the same as Route but adds a signature so the URL
+	// can't be changed. It stops working after expiresAt unless that's
+	// zero. Routes check signatures with the "signed" middleware.
+	SignedRoute(name string, params RouteParams, expiresAt time.Time) string
+
+	// HasValidSignature returns whether the request is for a URL from
+	// SignedRoute that hasn't expired
+	HasValidSignature(req Request) bool
+
 	// URL returns a URL based on an assigned route name
 	URL(path string) string
 
-	// Route resolves a URL from a route name combined with parameters
+	// Route resolves a URL from a route name combined with parameters. It
+	// panics if the URL can't be built, so use RouteURL for names or
+	// params that aren't known to be valid.
 	Route(name string, params RouteParams) string
 
+	// RouteURL resolves a URL from a route name. Params the route doesn't
+	// use are added to the query string. URLs are absolute, using the
+	// route's host if it has one or the app's URL otherwise.
+	RouteURL(name string, params RouteParams, options ...URLOptions) (string, error)
+
+	// Routes describes every route that's been registered
+	Routes() []RouteInfo
+
+	// OpenAPI describes the routes with an OpenAPI document. Parameters and
+	// request bodies come from the structs callbacks bind and responses
+	// from Route.Returns. Routes matching any method are left out.
+	OpenAPI(info openapi.Info) *openapi.Document
+
+	// ServeOpenAPI adds a route responding with the OpenAPI document
+	ServeOpenAPI(uri string, info openapi.Info) Route
+
+	// ServeAPIDocs adds a page browsing the OpenAPI document. It's only
+	// shown in development.
+	ServeAPIDocs(uri string, info openapi.Info) Route
+
 	// TODO: Make this private
 	Handler() http.Handler
 }
 
 type RouteParams map[string]string
 
+// URLOptions customizes URLs built by Router.RouteURL
+type URLOptions struct {
+	// Relative leaves out the scheme and host
+	Relative bool
+
+	// Fragment is added to the end after a #
+	Fragment string
+}
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	// Methods is empty if the route matches any method
+	Methods []string `json:"methods"`
+	Path    string   `json:"path"`
+	Host    string   `json:"host"`
+	Name    string   `json:"name"`
+
+	// Middleware names the middleware the route runs, by alias or function.
+	// The router's global middleware comes first, then that of the groups
+	// the route is in, then the route's own.
+	Middleware []string `json:"middleware"`
+
+	// Handler names the route's callback function
+	Handler string `json:"handler"`
+}
+
+// ResourceOptions customizes the routes added by Router.Resource
+type ResourceOptions struct {
+	// Only limits the routes to these actions, like "index" and "show"
+	Only []string
+
+	// Except skips the routes for these actions
+	Except []string
+
+	// Shallow leaves the parents out of nested routes that identify a
+	// single resource, like /comments/{comment} instead of
+	// /posts/{post}/comments/{comment}
+	Shallow bool
+
+	// Parameters overrides the route parameter names for resources, which
+	// default to their singular name
+	Parameters map[string]string
+
+	// Middleware is added to every route
+	Middleware []interface{}
+}
+
 // Route represents an HTTP route
 type Route interface {
 	Callback(callback Callback) Route
@@ -180,14 +591,52 @@ type Route interface {
 	Methods(methods ...string) Route
 
 	Redirect(uri, to string, code int) Route
+	WebSocket(uri string, callback Callback, options ...websocket.Options) Route
 
 	// Name assigns a name to the route for referencing
 	Name(name string) Route
 	Host(uri string) Route
 	Prefix(uri string) Route
 	Group(func(Router))
+
+	// MaxBodySize limits the size of request bodies accepted by the route.
+	// Larger requests are rejected with a 413 response.
+	MaxBodySize(bytes int64) Route
+
+	// MaxFileSize limits the size of each file uploaded to the route. Larger
+	// files are rejected with a 413 response.
+	MaxFileSize(bytes int64) Route
+
+	// CSRFExempt disables CSRF verification for the route, for things like
+	// webhooks that are called by other servers
+	CSRFExempt() Route
+
+	// ModelParams names the route parameters callback arguments with a
+	// Model lookup get, in the order the arguments are declared. This lets
+	// a route take two of the same model or use a different parameter name.
+	//
+	// For example:
+	//
+	//     r.Post("/users/{user}/follow/{other}", func(user, other *User, res Response) Result {
+	//         ...
+	//     }).ModelParams("user", "other")
+	ModelParams(params ...string) Route
+
+	// Returns declares a response for the OpenAPI document. v is a value of
+	// the type responded with as JSON, or nil if there's no body.
+	Returns(status int, v interface{}) Route
+
+	// Summary describes the route in the OpenAPI document
+	Summary(summary string) Route
+
 	With(...Middleware) Route
 	WithG(...func(http.Handler) http.Handler) Route
+
+	// Middleware adds middleware to this route only. Middleware can be
+	// hemlock middleware, native middleware or the name of an alias.
+	Middleware(...interface{}) Route
+
+	// Use adds middleware to this route only
 	Use(...Middleware)
 	UseG(...func(http.Handler) http.Handler)
 }
@@ -195,8 +644,21 @@ type Route interface {
 // Callback is a function that takes injected arguments
 type Callback interface{}
 
-// Next is called to continue the chain of  middleware
+// Next is called to continue the chain of middleware. It returns the Result
+// of the rest of the chain. Status and headers set on res beforehand are
+// kept.
 type Next func(req Request, res Response) Result
 
-// Middleware is an interface for adding middleware to a Router instance
+// Middleware is an interface for adding middleware to a Router instance. It
+// should either return the Result from next or respond itself to stop the
+// request going any further.
+//
+// For example:
+//
+//	func timing(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+//		start := time.Now()
+//		result := next(req, res)
+//		result.Header().Set("Server-Timing", fmt.Sprintf("app;dur=%d", time.Since(start).Milliseconds()))
+//		return result
+//	}
 type Middleware func(req Request, res Response, next Next) Result
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/alecthomas/kingpin"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	cmd := Command("routes", "List the routes of a Hemlock project")
+
+	cmdSrc := cmd.Arg("dest", "").Default(".").String()
+	cmdJSON := cmd.Flag("json", "Print routes as JSON").Bool()
+	cmdName := cmd.Flag("name", "Only show routes with names containing this").String()
+	cmdMethod := cmd.Flag("method", "Only show routes matching this method").String()
+	cmdPath := cmd.Flag("path", "Only show routes with paths containing this").String()
+
+	cmd.Action(func(context *kingpin.ParseContext) error {
+		routes, err := loadRoutes(*cmdSrc)
+		if err != nil {
+			return err
+		}
+
+		routes = filterRoutes(routes, *cmdName, *cmdMethod, *cmdPath)
+
+		if *cmdJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(routes)
+		}
+
+		printRoutes(os.Stdout, routes)
+		return nil
+	})
+}
+
+// loadRoutes builds the app and starts it without listening so it writes
+// its route table to a temp file
+func loadRoutes(srcDir string) ([]interfaces.RouteInfo, error) {
+	dir, err := ioutil.TempDir("", "hemlock-routes-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	bin := filepath.Join(dir, "app")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	build.Dir = srcDir
+	build.Stdout = os.Stderr
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return nil, fmt.Errorf("failed to build app: %v", err)
+	}
+
+	routesFile := filepath.Join(dir, "routes.json")
+	run := exec.Command(bin)
+	run.Dir = srcDir
+	run.Env = append(os.Environ(), hemlock.RoutesFileEnv+"="+routesFile)
+
+	// Anything the app prints would get mixed up with the routes
+	run.Stdout = ioutil.Discard
+	run.Stderr = os.Stderr
+	if err := run.Run(); err != nil {
+		return nil, fmt.Errorf("failed to start app: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(routesFile)
+	if err != nil {
+		return nil, fmt.Errorf("app did not write routes. Does it call Start()? %v", err)
+	}
+
+	var routes []interfaces.RouteInfo
+	err = json.Unmarshal(b, &routes)
+	return routes, err
+}
+
+func filterRoutes(routes []interfaces.RouteInfo, name, method, path string) []interfaces.RouteInfo {
+	filtered := make([]interfaces.RouteInfo, 0, len(routes))
+	for _, r := range routes {
+		if name != "" && !strings.Contains(r.Name, name) {
+			continue
+		}
+
+		if path != "" && !strings.Contains(r.Path, path) {
+			continue
+		}
+
+		if method != "" && len(r.Methods) > 0 && !hasMethod(r.Methods, method) {
+			continue
+		}
+
+		filtered = append(filtered, r)
+	}
+
+	return filtered
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func printRoutes(w io.Writer, routes []interfaces.RouteInfo) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tNAME\tHANDLER\tMIDDLEWARE")
+	for _, r := range routes {
+		methods := strings.Join(r.Methods, "|")
+		if methods == "" {
+			methods = "ANY"
+		}
+
+		path := r.Path
+		if r.Host != "" {
+			path = r.Host + path
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", methods, path, r.Name, r.Handler, strings.Join(r.Middleware, ","))
+	}
+	tw.Flush()
+}
@@ -24,7 +24,9 @@ func Clone(c *Container, serviceConstructorArgs []interface{}) *Container {
 
 	c.registeredMutex.Lock()
 	defer c.registeredMutex.Unlock()
-	copy(newContainer.registered, c.registered)
+	for _, sw := range c.registered {
+		newContainer.registered = append(newContainer.registered, sw.clone(serviceConstructorArgs))
+	}
 
 	return newContainer
 }
@@ -32,18 +34,24 @@ func Clone(c *Container, serviceConstructorArgs []interface{}) *Container {
 // Bind binds the type of v as a dependency
 func (c *Container) Bind(fn interface{}) {
 	w := newServiceWrapper(fn, false, c.serviceConstructorArgs)
-	c.registered = append(c.registered, w)
+	c.register(w)
 }
 
 // Singleton binds the type of v as a dependency. Will only get instantiated once
 func (c *Container) Singleton(fn interface{}) {
 	w := newServiceWrapper(fn, true, c.serviceConstructorArgs)
-	c.registered = append(c.registered, w)
+	c.register(w)
 }
 
 // Instance binds an already-created value as a dependency
 func (c *Container) Instance(i interface{}) {
 	w := newServiceWrapperInstance(i, true)
+	c.register(w)
+}
+
+func (c *Container) register(w *serviceWrapper) {
+	c.registeredMutex.Lock()
+	defer c.registeredMutex.Unlock()
 	c.registered = append(c.registered, w)
 }
 
@@ -63,6 +71,39 @@ func (c *Container) Make(i interface{}) interface{} {
 	return sw.Make()
 }
 
+// Has returns whether anything is bound that Make could return for i
+func (c *Container) Has(i interface{}) bool {
+	iType := reflect.TypeOf(i)
+	if iType.Kind() != reflect.Ptr {
+		panic("Cannot check non-pointer")
+	}
+
+	if iType.Elem().Kind() == reflect.Interface {
+		return c.lookupByInterface(iType.Elem()) != nil
+	}
+
+	return c.lookupByPtr(iType) != nil
+}
+
+// Bindings describes everything registered, in registration order
+func (c *Container) Bindings() []string {
+	c.registeredMutex.Lock()
+	defer c.registeredMutex.Unlock()
+
+	bindings := make([]string, len(c.registered))
+	for i, sw := range c.registered {
+		kind := "bind"
+		if sw.constructor == nil {
+			kind = "instance"
+		} else if sw.singleton {
+			kind = "singleton"
+		}
+		bindings[i] = sw.instanceType.String() + " (" + kind + ")"
+	}
+
+	return bindings
+}
+
 func (c *Container) Resolve(v interface{}) {
 	vType, vValue := getTypeAndValue(v)
 
@@ -78,6 +119,44 @@ func (c *Container) Resolve(v interface{}) {
 	}
 }
 
+// Resolver provides values for arguments the container can't. It returns
+// false if it doesn't handle the type.
+type Resolver func(argType reflect.Type) (reflect.Value, bool, error)
+
+// CallWith calls fn, asking the resolver for each argument before falling
+// back to the container. If the resolver fails, fn isn't called and the
+// error is returned.
+func (c *Container) CallWith(fn interface{}, resolver Resolver) ([]interface{}, error) {
+	fnType := reflect.TypeOf(fn)
+	fnValue := reflect.ValueOf(fn)
+	if fnType.Kind() != reflect.Func {
+		panic("Cannot provide to non-function")
+	}
+
+	args := make([]reflect.Value, fnType.NumIn())
+	for i := range args {
+		argType := fnType.In(i)
+		v, ok, err := resolver(argType)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			v = c.makeArg(argType)
+		}
+
+		args[i] = v
+	}
+
+	returnValues := fnValue.Call(args)
+	returnInstances := make([]interface{}, len(returnValues))
+	for i, rv := range returnValues {
+		returnInstances[i] = rv.Interface()
+	}
+
+	return returnInstances, nil
+}
+
 func (c *Container) Call(fn interface{}, extraArgs []interface{}) []interface{} {
 	fnType := reflect.TypeOf(fn)
 	fnValue := reflect.ValueOf(fn)
@@ -91,22 +170,7 @@ func (c *Container) Call(fn interface{}, extraArgs []interface{}) []interface{}
 
 	// Build argument values one-by-one
 	for i := 0; i < numArgsToFill; i++ {
-		argType := fnType.In(i)
-		var sw *serviceWrapper
-		switch argType.Kind() {
-		case reflect.Interface:
-			sw = c.findServiceWrapperByInterface(argType)
-		case reflect.Ptr:
-			sw = c.findServiceWrapperByPtr(argType)
-		default:
-			sw = c.FindServiceWrapperByValue(argType)
-		}
-
-		if sw == nil {
-			log.Panicf("Failed to find correct type %v\n", argType)
-		}
-
-		filledArgs[i] = reflect.ValueOf(sw.Make())
+		filledArgs[i] = c.makeArg(fnType.In(i))
 	}
 
 	allArgs := append(filledArgs, getValues(extraArgs)...)
@@ -119,11 +183,38 @@ func (c *Container) Call(fn interface{}, extraArgs []interface{}) []interface{}
 	return returnInstances
 }
 
+func (c *Container) makeArg(argType reflect.Type) reflect.Value {
+	var sw *serviceWrapper
+	switch argType.Kind() {
+	case reflect.Interface:
+		sw = c.findServiceWrapperByInterface(argType)
+	case reflect.Ptr:
+		sw = c.findServiceWrapperByPtr(argType)
+	default:
+		sw = c.FindServiceWrapperByValue(argType)
+	}
+
+	if sw == nil {
+		log.Panicf("Failed to find correct type %v\n", argType)
+	}
+
+	return reflect.ValueOf(sw.Make())
+}
+
 func (c *Container) findServiceWrapperByInterface(iType reflect.Type) *serviceWrapper {
 	if iType.Kind() != reflect.Interface {
 		panic("Argument type must be an interface")
 	}
 
+	matchedSW := c.lookupByInterface(iType)
+	if matchedSW == nil {
+		log.Panicf("Could not resolve anything for interface %v out of %v\n", iType, c.registered)
+	}
+
+	return matchedSW
+}
+
+func (c *Container) lookupByInterface(iType reflect.Type) *serviceWrapper {
 	var matchedSW *serviceWrapper
 	leastMethods := -1
 	c.registeredMutex.Lock()
@@ -144,10 +235,6 @@ func (c *Container) findServiceWrapperByInterface(iType reflect.Type) *serviceWr
 		matchedSW = sw
 	}
 
-	if matchedSW == nil {
-		log.Panicf("Could not resolve anything for interface %v out of %v\n", iType, c.registered)
-	}
-
 	return matchedSW
 }
 
@@ -156,9 +243,21 @@ func (c *Container) findServiceWrapperByPtr(ptrType reflect.Type) *serviceWrappe
 		panic("Argument type must be an pointer")
 	}
 
+	sw := c.lookupByPtr(ptrType)
+	if sw == nil {
+		log.Panicf("Could not resolve anything for ptr %v out of %v\n", ptrType, c.registered)
+	}
+
+	return sw
+}
+
+func (c *Container) lookupByPtr(ptrType reflect.Type) *serviceWrapper {
 	c.registeredMutex.Lock()
 	defer c.registeredMutex.Unlock()
-	for _, sw := range c.registered {
+
+	// Search backwards so later registrations override earlier ones
+	for i := len(c.registered) - 1; i >= 0; i-- {
+		sw := c.registered[i]
 		//fmt.Printf("Checking Ptr %v =? %v\n", ptrType, sw.instanceType)
 		// TODO: Find best match interface
 		if sw.instanceType.Kind() == reflect.Interface && ptrType.Implements(sw.instanceType) {
@@ -170,14 +269,14 @@ func (c *Container) findServiceWrapperByPtr(ptrType reflect.Type) *serviceWrappe
 		}
 	}
 
-	log.Panicf("Could not resolve anything for ptr %v out of %v\n", ptrType, c.registered)
 	return nil
 }
 
 func (c *Container) FindServiceWrapperByValue(valueType reflect.Type) *serviceWrapper {
 	c.registeredMutex.Lock()
 	defer c.registeredMutex.Unlock()
-	for _, sw := range c.registered {
+	for i := len(c.registered) - 1; i >= 0; i-- {
+		sw := c.registered[i]
 		if sw.instanceType.AssignableTo(valueType) {
 			return sw
 		}
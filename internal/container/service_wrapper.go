@@ -3,9 +3,11 @@ package container
 import (
 	"log"
 	"reflect"
+	"sync"
 )
 
 type serviceWrapper struct {
+	mutex           sync.Mutex
 	singleton       bool
 	cachedInstance  interface{}
 	constructor     interface{}
@@ -48,7 +50,25 @@ func newServiceWrapperInstance(instance interface{}, singleton bool) *serviceWra
 	}
 }
 
+// clone returns a copy of the wrapper for use in a cloned container.
+// Singletons and instances are shared so there is only ever one of them.
+func (sw *serviceWrapper) clone(constructorArgs []interface{}) *serviceWrapper {
+	if sw.singleton {
+		return sw
+	}
+
+	return &serviceWrapper{
+		singleton:       false,
+		constructor:     sw.constructor,
+		constructorArgs: constructorArgs,
+		instanceType:    sw.instanceType,
+	}
+}
+
 func (sw *serviceWrapper) Make() interface{} {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+
 	// Return cached instance if it's a singleton
 	if sw.singleton && sw.cachedInstance != nil {
 		return sw.cachedInstance
@@ -0,0 +1,114 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/router"
+	"github.com/gschier/hemlock/internal/session"
+	"net/http"
+	"strings"
+)
+
+const (
+	// FieldName is the form field the token is read from
+	FieldName = "_token"
+
+	// HeaderName is the header the token is read from for AJAX requests
+	HeaderName = "X-CSRF-Token"
+
+	// CookieName is a cookie holding the token that JavaScript can read and
+	// send back in the XSRFHeaderName header
+	CookieName     = "XSRF-TOKEN"
+	XSRFHeaderName = "X-XSRF-Token"
+
+	// StatusTokenMismatch is sent when the token is missing or invalid
+	StatusTokenMismatch = 419
+
+	sessionKey = "_token"
+)
+
+// Token returns the session's CSRF token, creating one if needed
+func Token(sess interfaces.Session) string {
+	token := sess.Get(sessionKey)
+	if token == "" {
+		b := make([]byte, 32)
+		_, err := rand.Read(b)
+		if err != nil {
+			panic("Failed to generate CSRF token: " + err.Error())
+		}
+		token = hex.EncodeToString(b)
+		sess.Put(sessionKey, token)
+	}
+	return token
+}
+
+// Middleware rejects state-changing requests that don't include the
+// session's CSRF token, unless the matched route is exempt. Requests with
+// a bearer token and no session, like API clients, are skipped since
+// browsers don't send those on their own. Basic credentials are still
+// checked because browsers cache them and send them cross-site.
+func Middleware(rt *router.Router) interfaces.Middleware {
+	return func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+		sess := session.FromContext(req.Context())
+		if sess == nil {
+			panic("CSRF middleware requires the session middleware")
+		}
+
+		if sess.IsNew() && hasBearerToken(req) {
+			return next(req, res)
+		}
+
+		// Make sure a token exists before anything is rendered
+		token := Token(sess)
+		res.Cookie(&http.Cookie{Name: CookieName, Value: token, Path: "/", SameSite: http.SameSiteLaxMode})
+
+		if isReading(req.Method()) {
+			return next(req, res)
+		}
+
+		// Requests that don't match a route get a 404 or 405 instead
+		if route := rt.RouteFor(req.(*router.Request).R); route == nil || route.IsCSRFExempt() {
+			return next(req, res)
+		}
+
+		if !matches(token, requestToken(req)) {
+			return res.Error(hemlock.NewHTTPError(StatusTokenMismatch, "Page Expired"))
+		}
+
+		return next(req, res)
+	}
+}
+
+func hasBearerToken(req interfaces.Request) bool {
+	h := req.Header("Authorization")
+	return len(h) > len("Bearer ") && strings.EqualFold(h[:len("Bearer ")], "Bearer ")
+}
+
+func requestToken(req interfaces.Request) string {
+	if t := req.Header(HeaderName); t != "" {
+		return t
+	}
+
+	if t := req.Header(XSRFHeaderName); t != "" {
+		return t
+	}
+
+	return req.Post(FieldName)
+}
+
+func matches(expected, actual string) bool {
+	if actual == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+func isReading(method string) bool {
+	return method == http.MethodGet ||
+		method == http.MethodHead ||
+		method == http.MethodOptions ||
+		method == http.MethodTrace
+}
@@ -0,0 +1,207 @@
+package debug
+
+import (
+	"bufio"
+	"errors"
+	"github.com/gschier/hemlock/exceptions"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// sourceContext is the number of lines shown around each frame's line
+const sourceContext = 5
+
+// redactedHeaders are hidden from the page since it may be screenshotted
+// or shared
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Csrf-Token", "X-Xsrf-Token"}
+
+type SourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	Source   []SourceLine
+}
+
+type Cause struct {
+	Type    string
+	Message string
+}
+
+// Page holds everything shown on the development error page
+type Page struct {
+	Causes   []Cause
+	Frames   []Frame
+	Method   string
+	URL      string
+	Route    string
+	Params   map[string]string
+	Query    url.Values
+	Headers  map[string]string
+	Bindings []string
+}
+
+// NewPage builds a page for err. The stack comes from a recovered panic
+// if there was one, or the caller otherwise.
+func NewPage(err error, r *http.Request, route string, params map[string]string, bindings []string) *Page {
+	p := &Page{
+		Method:   r.Method,
+		URL:      r.URL.String(),
+		Route:    route,
+		Params:   params,
+		Query:    r.URL.Query(),
+		Headers:  make(map[string]string),
+		Bindings: bindings,
+	}
+
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		p.Causes = append(p.Causes, Cause{Type: reflect.TypeOf(e).String(), Message: e.Error()})
+	}
+
+	var frames []runtime.Frame
+	var panicErr *exceptions.PanicError
+	if errors.As(err, &panicErr) {
+		frames = panicErr.Frames
+	} else {
+		frames = exceptions.Stack(1)
+	}
+
+	for _, f := range frames {
+		p.Frames = append(p.Frames, Frame{
+			Function: f.Function,
+			File:     f.File,
+			Line:     f.Line,
+			Source:   readSource(f.File, f.Line),
+		})
+	}
+
+	for name, values := range r.Header {
+		p.Headers[name] = strings.Join(values, ", ")
+	}
+	for _, name := range redactedHeaders {
+		if _, ok := p.Headers[name]; ok {
+			p.Headers[name] = "[redacted]"
+		}
+	}
+
+	return p
+}
+
+func (p *Page) Render(w io.Writer) error {
+	return pageTemplate.Execute(w, p)
+}
+
+// SortedHeaders returns header names in alphabetical order
+func (p *Page) SortedHeaders() []string {
+	names := make([]string, 0, len(p.Headers))
+	for name := range p.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readSource returns the lines around line in file, or nothing if the file
+// can't be read
+func readSource(file string, line int) []SourceLine {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []SourceLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan() && n <= line+sourceContext; n++ {
+		if n >= line-sourceContext {
+			lines = append(lines, SourceLine{Number: n, Text: scanner.Text(), Current: n == line})
+		}
+	}
+
+	return lines
+}
+
+var pageTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ (index .Causes 0).Message }}</title>
+<style>
+body { margin: 0; font: 14px/1.5 -apple-system, BlinkMacSystemFont, sans-serif; color: #222; background: #f4f4f5; }
+header { padding: 24px 32px; background: #b91c1c; color: #fff; }
+header h1 { margin: 0 0 4px; font-size: 20px; }
+header p { margin: 0; opacity: 0.85; }
+section { margin: 24px 32px; background: #fff; border-radius: 6px; padding: 16px 24px; }
+h2 { font-size: 15px; margin: 0 0 12px; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: 4px 8px; vertical-align: top; border-top: 1px solid #eee; font-family: monospace; word-break: break-all; }
+td:first-child { width: 25%; color: #666; }
+details { border-top: 1px solid #eee; padding: 6px 0; }
+summary { cursor: pointer; font-family: monospace; }
+summary small { color: #666; }
+pre { margin: 8px 0; background: #18181b; color: #e4e4e7; padding: 8px 0; overflow-x: auto; }
+pre span { display: block; padding: 0 12px; }
+pre span.current { background: #7f1d1d; }
+pre i { display: inline-block; width: 40px; color: #71717a; font-style: normal; }
+</style>
+</head>
+<body>
+<header>
+	{{ range $i, $c := .Causes }}{{ if eq $i 0 }}
+	<h1>{{ $c.Message }}</h1>
+	<p>{{ $c.Type }} &middot; {{ $.Method }} {{ $.URL }}{{ if $.Route }} &middot; route {{ $.Route }}{{ end }}</p>
+	{{ end }}{{ end }}
+</header>
+
+{{ if gt (len .Causes) 1 }}
+<section>
+	<h2>Error chain</h2>
+	<table>{{ range .Causes }}<tr><td>{{ .Type }}</td><td>{{ .Message }}</td></tr>{{ end }}</table>
+</section>
+{{ end }}
+
+<section>
+	<h2>Stack trace</h2>
+	{{ range $i, $f := .Frames }}
+	<details{{ if eq $i 0 }} open{{ end }}>
+		<summary>{{ $f.Function }} <small>{{ $f.File }}:{{ $f.Line }}</small></summary>
+		{{ if $f.Source }}<pre>{{ range $f.Source }}<span{{ if .Current }} class="current"{{ end }}><i>{{ .Number }}</i>{{ .Text }}</span>{{ end }}</pre>{{ end }}
+	</details>
+	{{ end }}
+</section>
+
+<section>
+	<h2>Request</h2>
+	<table>
+		<tr><td>Method</td><td>{{ .Method }}</td></tr>
+		<tr><td>URL</td><td>{{ .URL }}</td></tr>
+		<tr><td>Route</td><td>{{ .Route }}</td></tr>
+		{{ range $name, $value := .Params }}<tr><td>Param {{ $name }}</td><td>{{ $value }}</td></tr>{{ end }}
+		{{ range $name, $values := .Query }}<tr><td>Query {{ $name }}</td><td>{{ $values }}</td></tr>{{ end }}
+	</table>
+</section>
+
+<section>
+	<h2>Headers</h2>
+	<table>{{ range .SortedHeaders }}<tr><td>{{ . }}</td><td>{{ index $.Headers . }}</td></tr>{{ end }}</table>
+</section>
+
+<section>
+	<h2>Container bindings</h2>
+	<table>{{ range .Bindings }}<tr><td colspan="2">{{ . }}</td></tr>{{ end }}</table>
+</section>
+</body>
+</html>
+`))
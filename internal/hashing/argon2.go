@@ -0,0 +1,142 @@
+package hashing
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const (
+	defaultArgon2Memory  = 64 * 1024
+	defaultArgon2Time    = 3
+	defaultArgon2Threads = 2
+	argon2SaltLength     = 16
+	argon2KeyLength      = 32
+
+	// Hashes asking for more than this are rejected rather than letting a
+	// stored hash use up the server's memory or CPU
+	maxArgon2Memory = 1024 * 1024
+	maxArgon2Time   = 32
+)
+
+// Argon2id hashes passwords into the PHC string format used by other
+// implementations, for example:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2id struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func NewArgon2id(memory, time uint32, threads uint8) *Argon2id {
+	if memory == 0 {
+		memory = defaultArgon2Memory
+	}
+	if time == 0 {
+		time = defaultArgon2Time
+	}
+	if threads == 0 {
+		threads = defaultArgon2Threads
+	}
+	return &Argon2id{memory: memory, time: time, threads: threads}
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.time, a.memory, a.threads, argon2KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		a.memory,
+		a.time,
+		a.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2id) Check(password, hash string) bool {
+	p, err := parseArgon2(hash)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1
+}
+
+func (a *Argon2id) NeedsRehash(hash string) bool {
+	p, err := parseArgon2(hash)
+	if err != nil {
+		return true
+	}
+	return p.memory < a.memory || p.time < a.time || p.threads < a.threads
+}
+
+func (a *Argon2id) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func parseArgon2(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return nil, err
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	var p argon2Params
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads)
+	if err != nil {
+		return nil, err
+	}
+	if p.time < 1 || p.time > maxArgon2Time {
+		return nil, fmt.Errorf("invalid argon2 time %d", p.time)
+	}
+	if p.threads < 1 {
+		return nil, fmt.Errorf("invalid argon2 threads %d", p.threads)
+	}
+	if p.memory > maxArgon2Memory {
+		return nil, fmt.Errorf("invalid argon2 memory %d", p.memory)
+	}
+
+	p.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, err
+	}
+
+	p.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, err
+	}
+	if len(p.salt) == 0 || len(p.key) == 0 {
+		return nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,72 @@
+package hashing
+
+import (
+	"fmt"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"strings"
+)
+
+// Driver hashes passwords with a single algorithm
+type Driver interface {
+	interfaces.Hasher
+
+	// Supports returns whether the hash was created by this driver's algorithm
+	Supports(hash string) bool
+}
+
+// Hasher hashes new passwords with the configured driver and checks
+// existing hashes with whichever driver created them
+type Hasher struct {
+	current Driver
+	drivers []Driver
+}
+
+func NewHasher(config *hemlock.HashingConfig) (*Hasher, error) {
+	if config == nil {
+		config = &hemlock.HashingConfig{}
+	}
+
+	bcryptDriver := NewBcrypt(config.BcryptCost)
+	argon2Driver := NewArgon2id(config.Argon2Memory, config.Argon2Time, config.Argon2Threads)
+
+	h := &Hasher{drivers: []Driver{bcryptDriver, argon2Driver}}
+	switch strings.ToLower(config.Driver) {
+	case "", "bcrypt":
+		h.current = bcryptDriver
+	case "argon2id", "argon2":
+		h.current = argon2Driver
+	default:
+		return nil, fmt.Errorf("unknown hashing driver %s", config.Driver)
+	}
+
+	return h, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *Hasher) Check(password, hash string) bool {
+	d := h.driverFor(hash)
+	if d == nil {
+		return false
+	}
+	return d.Check(password, hash)
+}
+
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if !h.current.Supports(hash) {
+		return true
+	}
+	return h.current.NeedsRehash(hash)
+}
+
+func (h *Hasher) driverFor(hash string) Driver {
+	for _, d := range h.drivers {
+		if d.Supports(hash) {
+			return d
+		}
+	}
+	return nil
+}
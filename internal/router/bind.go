@@ -0,0 +1,183 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var uploadedFileType = reflect.TypeOf((*interfaces.UploadedFile)(nil)).Elem()
+
+var inputType = reflect.TypeOf(hemlock.Input{})
+
+// bindTags are the struct tags that mark a callback argument as something
+// to populate from the request. `json` isn't one of them because plenty of
+// services and models use it, so structs only read from JSON bodies embed
+// hemlock.Input instead.
+var bindTags = []string{"route", "query", "form"}
+
+func isBindable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	if f, ok := t.FieldByName(inputType.Name()); ok && f.Anonymous && f.Type == inputType {
+		return true
+	}
+
+	for _, tag := range bindTags {
+		if hasTag(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (req *Request) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("bind destination must be a pointer to a struct")
+	}
+	return req.bind(rv.Elem())
+}
+
+// bind populates a struct from the body, route parameters and query string
+// of the request. Errors are HTTPErrors describing the response to send.
+func (req *Request) bind(v reflect.Value) error {
+	err := req.bindBody(v)
+	if err != nil {
+		return err
+	}
+
+	// Values from the URL are bound last so a body can't overwrite them.
+	// encoding/json matches fields case-insensitively, so {"id": 99} would
+	// otherwise replace a `route:"id"` field.
+	err = bindParams(v, mux.Vars(req.R))
+	if err != nil {
+		return err
+	}
+
+	err = bindValues(v, "query", req.R.URL.Query())
+	if err != nil {
+		return newArgError(http.StatusBadRequest, "Invalid query string: %v", err)
+	}
+
+	return nil
+}
+
+// bindBody decodes the request body based on its Content-Type
+func (req *Request) bindBody(v reflect.Value) error {
+	if !req.hasBody() {
+		return nil
+	}
+
+	var err error
+	contentType, _, _ := mime.ParseMediaType(req.Header("Content-Type"))
+	switch {
+	case contentType == "application/json" || strings.HasSuffix(contentType, "+json"):
+		err = json.NewDecoder(req.R.Body).Decode(v.Addr().Interface())
+		if err == io.EOF {
+			err = nil
+		}
+	case contentType == "application/x-www-form-urlencoded":
+		err = req.R.ParseForm()
+		if err == nil {
+			err = bindValues(v, "form", req.R.PostForm)
+		}
+	case contentType == "multipart/form-data":
+		err = req.parseMultipart()
+		if err == nil {
+			err = bindValues(v, "form", req.R.MultipartForm.Value)
+		}
+		if err == nil {
+			req.bindFiles(v)
+		}
+	default:
+		return newArgError(http.StatusUnsupportedMediaType, "Unsupported content type %s", contentType)
+	}
+
+	if body, ok := req.R.Body.(*limitedBody); ok && body.exceeded {
+		return newArgError(http.StatusRequestEntityTooLarge, "")
+	} else if err != nil {
+		return newArgError(http.StatusBadRequest, "Invalid request body: %v", err)
+	}
+
+	return nil
+}
+
+func (req *Request) hasBody() bool {
+	if req.R.Body == nil || req.R.Body == http.NoBody {
+		return false
+	}
+	return req.R.ContentLength != 0 || len(req.R.TransferEncoding) > 0
+}
+
+// bindFiles sets UploadedFile fields from the form field named by their tag
+func (req *Request) bindFiles(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := formName(t.Field(i))
+		if !ok {
+			continue
+		}
+
+		field := v.Field(i)
+		files := req.Files(name)
+		switch {
+		case field.Type() == uploadedFileType && len(files) > 0:
+			field.Set(reflect.ValueOf(files[0]))
+		case field.Type() == reflect.SliceOf(uploadedFileType):
+			field.Set(reflect.ValueOf(files))
+		}
+	}
+}
+
+// bindValues sets fields tagged with tag from the matching values. Form
+// fields fall back to their json name so one struct can accept either.
+func bindValues(v reflect.Value, tag string, values map[string][]string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		var name string
+		var ok bool
+		if tag == "form" {
+			name, ok = formName(f)
+		} else {
+			name, ok = tagName(f, tag)
+		}
+		if !ok || f.Type == uploadedFileType || f.Type == reflect.SliceOf(uploadedFileType) {
+			continue
+		}
+
+		vs, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		err := setField(v.Field(i), vs)
+		if err != nil {
+			return errors.New(name + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func formName(f reflect.StructField) (string, bool) {
+	if name, ok := tagName(f, "form"); ok {
+		return name, true
+	}
+	return tagName(f, "json")
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+type contextKey string
+
+const instancesContextKey = contextKey("instances")
+
+// WithInstance returns a copy of ctx carrying v. Any value added this way
+// is bound into the container of the route handling the request so it can
+// be injected into callbacks.
+func WithInstance(ctx context.Context, v interface{}) context.Context {
+	existing := instancesFromContext(ctx)
+	instances := make([]interface{}, len(existing), len(existing)+1)
+	copy(instances, existing)
+	return context.WithValue(ctx, instancesContextKey, append(instances, v))
+}
+
+func instancesFromContext(ctx context.Context) []interface{} {
+	instances, _ := ctx.Value(instancesContextKey).([]interface{})
+	return instances
+}
+
+// OnBeforeWrite wraps w so fn is called once, right before the status and
+// headers are written. This gives middleware a chance to add headers (like
+// cookies) based on what happened while handling the request.
+func OnBeforeWrite(w http.ResponseWriter, fn func()) *HookedResponseWriter {
+	return &HookedResponseWriter{ResponseWriter: w, hook: fn}
+}
+
+type HookedResponseWriter struct {
+	http.ResponseWriter
+	hook   func()
+	called bool
+}
+
+// Done calls the hook if nothing has been written yet
+func (w *HookedResponseWriter) Done() {
+	w.callHook()
+}
+
+func (w *HookedResponseWriter) WriteHeader(status int) {
+	w.callHook()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *HookedResponseWriter) Write(b []byte) (int, error) {
+	w.callHook()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *HookedResponseWriter) Flush() {
+	w.callHook()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack calls the hook first so its headers can still be sent, like with
+// a WebSocket handshake
+func (w *HookedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	w.callHook()
+	return h.Hijack()
+}
+
+func (w *HookedResponseWriter) callHook() {
+	if w.called {
+		return
+	}
+	w.called = true
+	w.hook()
+}
@@ -0,0 +1,255 @@
+package router
+
+import (
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/templates"
+	"log"
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// maxAliasDepth stops aliases that refer to each other from looping forever
+const maxAliasDepth = 16
+
+type middlewareContainer struct {
+	hemlock interfaces.Middleware
+	native  func(http.Handler) http.Handler
+
+	// name is the alias the middleware was added by. If neither hemlock nor
+	// native is set, it still needs to be looked up.
+	name string
+}
+
+// String returns the alias the middleware was added by, or the name of its
+// function
+func (mc *middlewareContainer) String() string {
+	switch {
+	case mc.name != "":
+		return mc.name
+	case mc.hemlock != nil:
+		return funcName(mc.hemlock)
+	}
+	return funcName(mc.native)
+}
+
+// newMiddlewareContainer accepts hemlock middleware, native middleware or
+// the name of an alias
+func newMiddlewareContainer(m interface{}) *middlewareContainer {
+	switch m := m.(type) {
+	case interfaces.Middleware:
+		return &middlewareContainer{hemlock: m}
+	case func(interfaces.Request, interfaces.Response, interfaces.Next) interfaces.Result:
+		return &middlewareContainer{hemlock: m}
+	case func(http.Handler) http.Handler:
+		return &middlewareContainer{native: m}
+	case string:
+		return &middlewareContainer{name: m}
+	}
+
+	log.Panicf("Unsupported middleware type %T", m)
+	return nil
+}
+
+// middlewareChain caches a list of middleware with its aliases expanded
+// and sorted by priority, so that only happens once rather than on every
+// request
+type middlewareChain struct {
+	resolved atomic.Value
+}
+
+type resolvedChain struct {
+	middlewares []*middlewareContainer
+	ok          bool
+}
+
+func (c *middlewareChain) load() ([]*middlewareContainer, bool) {
+	rc, _ := c.resolved.Load().(resolvedChain)
+	return rc.middlewares, rc.ok
+}
+
+func (c *middlewareChain) store(middlewares []*middlewareContainer) {
+	c.resolved.Store(resolvedChain{middlewares: middlewares, ok: true})
+}
+
+// reset makes the chain resolve again, after middleware or aliases change
+func (c *middlewareChain) reset() {
+	c.resolved.Store(resolvedChain{})
+}
+
+func (router *Router) Alias(name string, m ...interface{}) {
+	top := router.top
+	top.aliasMutex.Lock()
+	defer top.aliasMutex.Unlock()
+
+	if top.aliases == nil {
+		top.aliases = make(map[string][]interface{})
+	}
+	top.aliases[name] = m
+	top.routes.resetChains()
+}
+
+func (router *Router) MiddlewarePriority(names ...string) {
+	top := router.top
+	top.aliasMutex.Lock()
+	defer top.aliasMutex.Unlock()
+	top.priority = names
+	top.routes.resetChains()
+}
+
+// resolveMiddleware looks up aliases and sorts the result by priority. This
+// happens when the handler is built, or the first time the middleware runs
+// if it was added later, so aliases can be registered after the routes
+// using them.
+func (router *Router) resolveMiddleware(middlewares []*middlewareContainer, chain *middlewareChain) []*middlewareContainer {
+	if resolved, ok := chain.load(); ok {
+		return resolved
+	}
+
+	top := router.top
+	top.aliasMutex.RLock()
+	defer top.aliasMutex.RUnlock()
+
+	resolved := top.expandAliases(middlewares, "", 0)
+	top.sortByPriority(resolved)
+	chain.store(resolved)
+	return resolved
+}
+
+// resolveAll resolves the middleware of every router and route, so unknown
+// aliases are reported when the handler is built instead of on a request
+func (router *Router) resolveAll() {
+	for _, r := range router.routes.allRouters() {
+		r.resolveMiddleware(r.middlewares, &r.chain)
+	}
+	for _, r := range router.routes.allRoutes() {
+		r.router.resolveMiddleware(r.middlewares, &r.chain)
+	}
+}
+
+func (router *Router) expandAliases(middlewares []*middlewareContainer, name string, depth int) []*middlewareContainer {
+	if depth > maxAliasDepth {
+		log.Panicf("Middleware alias %s refers to itself", name)
+	}
+
+	var resolved []*middlewareContainer
+	for _, m := range middlewares {
+		if m.hemlock != nil || m.native != nil {
+			if m.name == "" && name != "" {
+				m = &middlewareContainer{hemlock: m.hemlock, native: m.native, name: name}
+			}
+			resolved = append(resolved, m)
+			continue
+		}
+
+		items, ok := router.aliases[m.name]
+		if !ok {
+			log.Panicf("Middleware alias %s is not registered", m.name)
+		}
+
+		aliased := make([]*middlewareContainer, len(items))
+		for i, item := range items {
+			aliased[i] = newMiddlewareContainer(item)
+		}
+
+		resolved = append(resolved, router.expandAliases(aliased, m.name, depth+1)...)
+	}
+
+	return resolved
+}
+
+// sortByPriority reorders the middleware named in the priority list to
+// match it. Middleware without priority keep their places. Each list is
+// sorted on its own, so global middleware always runs before a group's,
+// which runs before a route's.
+func (router *Router) sortByPriority(middlewares []*middlewareContainer) {
+	if len(router.priority) == 0 {
+		return
+	}
+
+	rank := make(map[string]int, len(router.priority))
+	for i, name := range router.priority {
+		rank[name] = i
+	}
+
+	var slots []int
+	var ranked []*middlewareContainer
+	for i, m := range middlewares {
+		if _, ok := rank[m.name]; ok {
+			slots = append(slots, i)
+			ranked = append(ranked, m)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return rank[ranked[i].name] < rank[ranked[j].name]
+	})
+
+	for i, slot := range slots {
+		middlewares[slot] = ranked[i]
+	}
+}
+
+// runMiddleware calls the middleware in order, followed by fn
+func (router *Router) runMiddleware(
+	middlewares []*middlewareContainer,
+	chain *middlewareChain,
+	w http.ResponseWriter,
+	r *http.Request,
+	fn func(w http.ResponseWriter, r *http.Request),
+) {
+	if len(middlewares) == 0 {
+		fn(w, r)
+		return
+	}
+
+	router.nextMiddleware(router.resolveMiddleware(middlewares, chain), 0, w, r, fn)
+}
+
+func (router *Router) nextMiddleware(
+	middlewares []*middlewareContainer,
+	i int,
+	w http.ResponseWriter,
+	r *http.Request,
+	fn func(w http.ResponseWriter, r *http.Request),
+) {
+	if i == len(middlewares) {
+		fn(w, r)
+		return
+	}
+
+	m := middlewares[i]
+	if m.hemlock != nil {
+		var renderer templates.Renderer
+		router.app.Resolve(&renderer)
+
+		p := pipelineFromContext(r.Context())
+		next := func(newReq interfaces.Request, newRes interfaces.Response) interfaces.Result {
+			res := newRes.(*Response)
+			if p != nil && res.status != 0 {
+				p.status = res.status
+			}
+
+			router.nextMiddleware(middlewares, i+1, res.W, newReq.(*Request).R, fn)
+
+			if p != nil && p.result != nil {
+				return p.result
+			}
+
+			// Nothing downstream returned a Result, like a native handler
+			return newResult(res.W, newReq.(*Request).R, 0, &renderer, router, router.app)
+		}
+
+		req := newRequest(r)
+		res := newResponse(w, req, &renderer, router, router.app)
+		if p != nil {
+			res.status = p.status
+		}
+
+		setResult(r, m.hemlock(req, res, next))
+	} else {
+		m.native(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			router.nextMiddleware(middlewares, i+1, w, r, fn)
+		})).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/gschier/hemlock"
+	"log"
+	"net/http"
+	"reflect"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	stringType = reflect.TypeOf("")
+)
+
+// modelBinder looks up callback arguments from a route parameter
+type modelBinder struct {
+	param  string
+	lookup interface{}
+}
+
+func (router *Router) Model(param string, lookup interface{}) {
+	t := reflect.TypeOf(lookup)
+	if t == nil || t.Kind() != reflect.Func || t.NumOut() < 1 || t.NumOut() > 2 ||
+		(t.NumOut() == 2 && t.Out(1) != errorType) {
+		panic("Model lookup must be a function returning a value and optionally an error")
+	}
+
+	top := router.top
+	top.aliasMutex.Lock()
+	defer top.aliasMutex.Unlock()
+
+	if top.models == nil {
+		top.models = make(map[reflect.Type]*modelBinder)
+	}
+	if _, ok := top.models[t.Out(0)]; ok {
+		log.Panicf("Model lookup for %v is already registered", t.Out(0))
+	}
+	top.models[t.Out(0)] = &modelBinder{param: param, lookup: lookup}
+}
+
+func (router *Router) modelBinder(t reflect.Type) *modelBinder {
+	top := router.top
+	top.aliasMutex.RLock()
+	defer top.aliasMutex.RUnlock()
+	return top.models[t]
+}
+
+// bind calls the lookup with the value of the route parameter. It's not
+// handled if the route doesn't have the parameter. Lookups that find
+// nothing respond with a 404.
+func (binder *modelBinder) bind(param string, r *http.Request, app *hemlock.Application) (reflect.Value, bool, error) {
+	value, ok := mux.Vars(r)[param]
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+
+	// The lookup gets the parameter as its string argument and anything
+	// else from the container
+	results, err := app.ResolveIntoWith(binder.lookup, func(t reflect.Type) (reflect.Value, bool, error) {
+		if t == stringType {
+			return reflect.ValueOf(value), true, nil
+		}
+		return reflect.Value{}, false, nil
+	})
+	if err != nil {
+		return reflect.Value{}, false, err
+	}
+
+	if len(results) == 2 && results[1] != nil {
+		return reflect.Value{}, false, results[1].(error)
+	}
+
+	v := reflect.ValueOf(results[0])
+	if !v.IsValid() || v.IsZero() {
+		return reflect.Value{}, false, hemlock.NotFound()
+	}
+
+	return v, true, nil
+}
@@ -0,0 +1,274 @@
+package router
+
+import (
+	"bytes"
+	"github.com/gorilla/mux"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/openapi"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// routeResponse is a response declared with Route.Returns
+type routeResponse struct {
+	status int
+	t      reflect.Type
+}
+
+func (r *Route) Returns(status int, v interface{}) interfaces.Route {
+	r.responses = append(r.responses, routeResponse{status: status, t: reflect.TypeOf(v)})
+	return r
+}
+
+func (r *Route) Summary(summary string) interfaces.Route {
+	r.summary = summary
+	return r
+}
+
+func (router *Router) OpenAPI(info openapi.Info) *openapi.Document {
+	doc := openapi.New(info)
+	_ = router.top.mux.Walk(func(m *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		r := router.routes.get(m)
+		if r == nil || m.GetHandler() == nil || r.undocumented {
+			return nil
+		}
+
+		// Prefixes like the static handler match more than one path
+		re, _ := m.GetPathRegexp()
+		methods, _ := m.GetMethods()
+		if !strings.HasSuffix(re, "$") || len(methods) == 0 {
+			return nil
+		}
+
+		tpl, _ := m.GetPathTemplate()
+		path, params := openAPIPath(tpl)
+		for _, method := range methods {
+			doc.Operation(method, path, r.operation(doc, m.GetName(), params))
+		}
+
+		return nil
+	})
+
+	return doc
+}
+
+func (router *Router) ServeOpenAPI(uri string, info openapi.Info) interfaces.Route {
+	r := router.newRoute()
+	r.undocumented = true
+	return r.Get(uri, func(res interfaces.Response) interfaces.Result {
+		return res.JSON(http.StatusOK, router.OpenAPI(info))
+	})
+}
+
+func (router *Router) ServeAPIDocs(uri string, info openapi.Info) interfaces.Route {
+	r := router.newRoute()
+	r.undocumented = true
+	return r.Get(uri, func(res interfaces.Response) interfaces.Result {
+		if !router.app.IsDev() {
+			return res.Error(hemlock.NotFound())
+		}
+
+		var buf bytes.Buffer
+		if err := router.OpenAPI(info).RenderDocs(&buf); err != nil {
+			return res.Error(err)
+		}
+
+		return res.Header("Content-Type", "text/html; charset=utf-8").Data(buf.String())
+	})
+}
+
+// operation describes the route from its params, the request structs its
+// callback binds and the responses it declared
+func (r *Route) operation(doc *openapi.Document, name string, params []*openapi.Parameter) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: name,
+		Summary:     r.summary,
+		Parameters:  params,
+		Responses:   make(map[string]*openapi.Response),
+	}
+
+	validated := false
+	if t := reflect.TypeOf(r.callback); t != nil && t.Kind() == reflect.Func {
+		for i := 0; i < t.NumIn(); i++ {
+			in := t.In(i)
+			if !isBindable(in) {
+				continue
+			}
+
+			r.describeInput(doc, op, in)
+			validated = validated || hasTag(in, "validate")
+		}
+	}
+
+	for _, resp := range r.responses {
+		op.Responses[strconv.Itoa(resp.status)] = r.response(doc, resp)
+	}
+
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = &openapi.Response{Description: http.StatusText(http.StatusOK)}
+	}
+
+	if _, ok := op.Responses["422"]; validated && !ok {
+		op.Responses["422"] = &openapi.Response{
+			Description: http.StatusText(http.StatusUnprocessableEntity),
+			Content: map[string]*openapi.MediaType{
+				"application/json": {Schema: doc.Schema(reflect.TypeOf(struct {
+					Message string              `json:"message"`
+					Errors  map[string][]string `json:"errors"`
+				}{}))},
+			},
+		}
+	}
+
+	return op
+}
+
+// describeInput adds the params and body bound into a callback argument
+func (r *Route) describeInput(doc *openapi.Document, op *openapi.Operation, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		// Route params get the type of the field they're bound to
+		if name, ok := tagName(f, "route"); ok {
+			for _, p := range op.Parameters {
+				if p.Name == name && p.In == "path" {
+					s := doc.Schema(f.Type)
+					s.Pattern = p.Schema.Pattern
+					p.Schema = s
+				}
+			}
+		}
+
+		if name, ok := tagName(f, "query"); ok {
+			s := doc.Schema(f.Type)
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name:     name,
+				In:       "query",
+				Required: openapi.ApplyRules(s, f.Tag.Get("validate")),
+				Schema:   s,
+			})
+		}
+	}
+
+	if hasTag(t, "json") {
+		s := doc.StructSchema(t, "json")
+
+		// Fields bound from elsewhere aren't part of the body
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if _, ok := f.Tag.Lookup("json"); !ok && isBoundElsewhere(f) {
+				delete(s.Properties, f.Name)
+				s.Required = removeString(s.Required, f.Name)
+			}
+		}
+
+		setRequestBody(op, "application/json", s)
+	}
+
+	if hasTag(t, "form") {
+		s := doc.StructSchema(t, "form")
+		contentType := "application/x-www-form-urlencoded"
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, ok := tagName(f, "form")
+			if !ok {
+				continue
+			}
+
+			switch {
+			case f.Type == uploadedFileType:
+				s.Properties[name] = &openapi.Schema{Type: "string", Format: "binary"}
+			case f.Type.Kind() == reflect.Slice && f.Type.Elem() == uploadedFileType:
+				s.Properties[name] = &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string", Format: "binary"}}
+			default:
+				continue
+			}
+			contentType = "multipart/form-data"
+		}
+
+		setRequestBody(op, contentType, s)
+	}
+}
+
+func (r *Route) response(doc *openapi.Document, resp routeResponse) *openapi.Response {
+	description := http.StatusText(resp.status)
+	if description == "" {
+		description = "Status " + strconv.Itoa(resp.status)
+	}
+
+	out := &openapi.Response{Description: description}
+	if resp.t == nil {
+		return out
+	}
+
+	s := doc.Schema(resp.t)
+
+	// Successful JSON responses are wrapped in the configured envelope
+	if c := r.router.app.Config.HTTP; c != nil && c.JSONEnvelope != "" && resp.status < http.StatusBadRequest {
+		s = &openapi.Schema{
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{c.JSONEnvelope: s},
+			Required:   []string{c.JSONEnvelope},
+		}
+	}
+
+	out.Content = map[string]*openapi.MediaType{"application/json": {Schema: s}}
+	return out
+}
+
+func setRequestBody(op *openapi.Operation, contentType string, s *openapi.Schema) {
+	if op.RequestBody == nil {
+		op.RequestBody = &openapi.RequestBody{Content: make(map[string]*openapi.MediaType)}
+	}
+	op.RequestBody.Content[contentType] = &openapi.MediaType{Schema: s}
+	op.RequestBody.Required = op.RequestBody.Required || len(s.Required) > 0
+}
+
+func isBoundElsewhere(f reflect.StructField) bool {
+	for _, tag := range bindTags {
+		if _, ok := f.Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(values []string, s string) []string {
+	out := values[:0]
+	for _, v := range values {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// openAPIPath converts a mux path template like /users/{id:[0-9]+} to
+// /users/{id}, returning the params with their patterns
+func openAPIPath(tpl string) (string, []*openapi.Parameter) {
+	path, vars := parseTemplate(tpl)
+
+	params := make([]*openapi.Parameter, len(vars))
+	for i, v := range vars {
+		pattern := ""
+		if v.pattern != "" {
+			pattern = "^" + v.pattern + "$"
+		}
+
+		params[i] = &openapi.Parameter{
+			Name:     v.name,
+			In:       "path",
+			Required: true,
+			Schema:   &openapi.Schema{Type: "string", Pattern: pattern},
+		}
+	}
+
+	return path, params
+}
@@ -0,0 +1,224 @@
+package router
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/gschier/hemlock"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// newArgError returns an error that stops a route callback from being
+// called and responds with the status and message instead
+func newArgError(status int, format string, a ...interface{}) *hemlock.HTTPError {
+	return hemlock.NewHTTPError(status, fmt.Sprintf(format, a...))
+}
+
+// hasTag returns whether t is a struct (or pointer to one) with a field
+// using the tag
+func hasTag(t reflect.Type, tag string) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newTaggedValue creates a new value of t, which is a struct or a pointer
+// to one, and calls fn with the struct so it can be filled in
+func newTaggedValue(t reflect.Type, fn func(v reflect.Value) error) (reflect.Value, error) {
+	isPtr := t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+
+	v := reflect.New(t)
+	if err := fn(v.Elem()); err != nil {
+		return reflect.Value{}, err
+	}
+
+	if isPtr {
+		return v, nil
+	}
+	return v.Elem(), nil
+}
+
+// bindParams sets fields tagged with `route:"name"` from route variables.
+// Params that fail to convert mean the URL doesn't identify anything, so
+// they produce a 404.
+func bindParams(v reflect.Value, vars map[string]string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := tagName(t.Field(i), "route")
+		if !ok {
+			continue
+		}
+
+		value, ok := vars[name]
+		if !ok {
+			continue
+		}
+
+		err := setField(v.Field(i), []string{value})
+		if err != nil {
+			return newArgError(http.StatusNotFound, "Invalid route parameter %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// positionalResolver keeps callbacks written before params were bound by
+// name working. If the callback's last arguments are plain strings, one for
+// each route variable, they get the variables in the order they appear in
+// the host and path, like func(res Response, postID, commentID string).
+// Everything else is resolved by next.
+func positionalResolver(
+	callback interface{},
+	r *http.Request,
+	next func(reflect.Type) (reflect.Value, bool, error),
+) func(reflect.Type) (reflect.Value, bool, error) {
+	values := orderedVars(r)
+	fnType := reflect.TypeOf(callback)
+	first := fnType.NumIn() - len(values)
+	if len(values) == 0 || first < 0 {
+		return next
+	}
+
+	for i := first; i < fnType.NumIn(); i++ {
+		if fnType.In(i).Kind() != reflect.String {
+			return next
+		}
+	}
+
+	i := 0
+	return func(t reflect.Type) (reflect.Value, bool, error) {
+		arg := i
+		i++
+		if arg >= first {
+			return reflect.ValueOf(values[arg-first]).Convert(t), true, nil
+		}
+		return next(t)
+	}
+}
+
+// orderedVars returns the values of the matched route's host and path
+// variables in the order they appear
+func orderedVars(r *http.Request) []string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil
+	}
+
+	vars := mux.Vars(r)
+	var values []string
+	for _, get := range []func() (string, error){route.GetHostTemplate, route.GetPathTemplate} {
+		tpl, err := get()
+		if err != nil {
+			continue
+		}
+		_, tplVars := parseTemplate(tpl)
+		for _, v := range tplVars {
+			values = append(values, vars[v.name])
+		}
+	}
+
+	return values
+}
+
+// tagName returns the name from a struct tag like `route:"id,omitempty"`
+func tagName(f reflect.StructField, tag string) (string, bool) {
+	value, ok := f.Tag.Lookup(tag)
+	if !ok || value == "-" {
+		return "", false
+	}
+
+	name := strings.Split(value, ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+
+	return name, true
+}
+
+// setField converts string values to the field's type. Slices receive all
+// of the values, other types only the first.
+func setField(field reflect.Value, values []string) error {
+	if len(values) == 0 || !field.CanSet() {
+		return nil
+	}
+
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			err := setValue(slice.Index(i), value)
+			if err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		v := reflect.New(field.Type().Elem())
+		err := setValue(v.Elem(), value)
+		if err != nil {
+			return err
+		}
+		field.Set(v)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Slice:
+		// Only []byte makes it here
+		field.SetBytes([]byte(value))
+	default:
+		return fmt.Errorf("unsupported type %v", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gschier/hemlock/interfaces"
+	"net"
+	"net/http"
+)
+
+const pipelineContextKey = contextKey("pipeline")
+
+// maxBufferedBody is how much of a response body is held back before the
+// status and headers are sent anyway
+const maxBufferedBody = 64 << 10
+
+// pipeline tracks a request as it passes through the middleware chain
+type pipeline struct {
+	writer *bufferedWriter
+
+	// status was set by middleware before calling next
+	status int
+
+	// result is the Result most recently returned by the callback or a
+	// middleware
+	result interfaces.Result
+}
+
+func pipelineFromContext(ctx context.Context) *pipeline {
+	p, _ := ctx.Value(pipelineContextKey).(*pipeline)
+	return p
+}
+
+// setResult records the Result of a route so middleware can inspect it
+func setResult(r *http.Request, result interfaces.Result) {
+	if p := pipelineFromContext(r.Context()); p != nil && result != nil {
+		p.result = result
+	}
+}
+
+// withPipeline holds back the status and headers of responses until the
+// whole middleware chain has returned, so middleware can still change the
+// headers after calling next
+func withPipeline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &pipeline{writer: &bufferedWriter{ResponseWriter: w}}
+		ctx := context.WithValue(r.Context(), pipelineContextKey, p)
+		next.ServeHTTP(p.writer, r.WithContext(ctx))
+		p.writer.commit()
+	})
+}
+
+// bufferedWriter holds back the status, headers and start of the body until
+// it's committed. It commits when the request is done, when it's flushed or
+// once the body is larger than maxBufferedBody.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	committed bool
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if w.committed {
+		return w.ResponseWriter.Write(b)
+	}
+
+	n, _ := w.body.Write(b)
+	if w.body.Len() > maxBufferedBody {
+		w.commit()
+	}
+
+	return n, nil
+}
+
+func (w *bufferedWriter) Flush() {
+	w.commit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	// Nothing may be written once the connection is taken over
+	w.committed = true
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Status returns the status written so far, or 0 if there isn't one yet
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+}
@@ -0,0 +1,137 @@
+package router
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/gschier/hemlock/interfaces"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// routeRegistry maps gorilla routes back to the hemlock routes that created
+// them. It is shared by a root router and all of its forks.
+type routeRegistry struct {
+	routes  map[*mux.Route]*Route
+	routers []*Router
+	mutex   sync.RWMutex
+}
+
+func newRouteRegistry() *routeRegistry {
+	return &routeRegistry{routes: make(map[*mux.Route]*Route)}
+}
+
+func (rr *routeRegistry) add(r *Route) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	rr.routes[r.route] = r
+}
+
+func (rr *routeRegistry) addRouter(r *Router) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	rr.routers = append(rr.routers, r)
+}
+
+func (rr *routeRegistry) allRouters() []*Router {
+	rr.mutex.RLock()
+	defer rr.mutex.RUnlock()
+	return append([]*Router(nil), rr.routers...)
+}
+
+func (rr *routeRegistry) allRoutes() []*Route {
+	rr.mutex.RLock()
+	defer rr.mutex.RUnlock()
+	routes := make([]*Route, 0, len(rr.routes))
+	for _, r := range rr.routes {
+		routes = append(routes, r)
+	}
+	return routes
+}
+
+// resetChains makes every router and route resolve its middleware again
+func (rr *routeRegistry) resetChains() {
+	for _, r := range rr.allRouters() {
+		r.chain.reset()
+	}
+	for _, r := range rr.allRoutes() {
+		r.chain.reset()
+	}
+}
+
+func (rr *routeRegistry) get(m *mux.Route) *Route {
+	rr.mutex.RLock()
+	defer rr.mutex.RUnlock()
+	return rr.routes[m]
+}
+
+// RouteFor returns the route matched for the request, or nil if the request
+// didn't match one
+func (router *Router) RouteFor(r *http.Request) *Route {
+	m := mux.CurrentRoute(r)
+	if m == nil {
+		return nil
+	}
+	return router.routes.get(m)
+}
+
+func (router *Router) Routes() []interfaces.RouteInfo {
+	routes := make([]interfaces.RouteInfo, 0)
+	_ = router.top.mux.Walk(func(m *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		// Groups and prefixes are walked too but don't handle anything
+		r := router.routes.get(m)
+		if r == nil || m.GetHandler() == nil {
+			return nil
+		}
+
+		info := interfaces.RouteInfo{
+			Name:       m.GetName(),
+			Handler:    r.handlerName,
+			Middleware: r.middlewareNames(),
+		}
+		if info.Handler == "" {
+			info.Handler = funcName(r.callback)
+		}
+
+		info.Methods, _ = m.GetMethods()
+		info.Path, _ = m.GetPathTemplate()
+		info.Host, _ = m.GetHostTemplate()
+
+		routes = append(routes, info)
+		return nil
+	})
+
+	return routes
+}
+
+// middlewareNames names the middleware the route runs in order: the
+// router's global middleware, then that of the groups it's in, then its own
+func (r *Route) middlewareNames() []string {
+	lists := [][]*middlewareContainer{r.middlewares}
+	for router := r.router; router != nil; router = router.parent {
+		lists = append([][]*middlewareContainer{router.middlewares}, lists...)
+	}
+
+	names := make([]string, 0)
+	for _, list := range lists {
+		for _, mc := range list {
+			names = append(names, mc.String())
+		}
+	}
+	return names
+}
+
+// funcName returns the name of a function, like "main.HomeController.Index-fm"
+// for a method value
+func funcName(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return ""
+	}
+
+	if f := runtime.FuncForPC(v.Pointer()); f != nil {
+		return f.Name()
+	}
+
+	return ""
+}
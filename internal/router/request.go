@@ -4,18 +4,20 @@ import (
 	"context"
 	"github.com/gorilla/mux"
 	"github.com/gschier/hemlock/interfaces"
-	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
 type Request struct {
 	R *http.Request
+
+	uploadMemory int64
 }
 
 func newRequest(r *http.Request) *Request {
-	return &Request{R: r}
+	return &Request{R: r, uploadMemory: defaultUploadMemory}
 }
 
 func (req *Request) URL() *url.URL {
@@ -46,6 +48,18 @@ func (req *Request) RouteName() string {
 	return r.GetName()
 }
 
+func (req *Request) Param(name string) string {
+	return mux.Vars(req.R)[name]
+}
+
+func (req *Request) ParamInt(name string) int {
+	value, err := strconv.Atoi(req.Param(name))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
 func (req *Request) Query(name string) string {
 	return req.R.URL.Query().Get(name)
 }
@@ -63,7 +77,11 @@ func (req *Request) WithContext(ctx context.Context) interfaces.Request {
 }
 
 func (req *Request) Post(name string) string {
-	req.R.ParseForm()
+	if req.isMultipart() {
+		req.parseMultipart()
+	} else {
+		req.R.ParseForm()
+	}
 	return req.R.Form.Get(name)
 }
 
@@ -71,10 +89,54 @@ func (req *Request) Cookie(name string) string {
 	panic("Implement me")
 }
 
-func (req *Request) File(name string) io.Reader {
-	panic("Implement me")
+func (req *Request) File(name string) interfaces.UploadedFile {
+	files := req.Files(name)
+	if len(files) == 0 {
+		return nil
+	}
+	return files[0]
+}
+
+func (req *Request) Files(name string) []interfaces.UploadedFile {
+	if err := req.parseMultipart(); err != nil {
+		return nil
+	}
+
+	headers := req.R.MultipartForm.File[name]
+	files := make([]interfaces.UploadedFile, len(headers))
+	for i, h := range headers {
+		files[i] = newUploadedFile(h)
+	}
+
+	return files
+}
+
+func (req *Request) WantsJSON() bool {
+	return strings.Contains(req.Header("Accept"), "json") ||
+		strings.Contains(req.Header("Content-Type"), "json") ||
+		req.Header("X-Requested-With") == "XMLHttpRequest"
 }
 
 func (req *Request) Context() context.Context {
 	return req.R.Context()
 }
+
+func (req *Request) isMultipart() bool {
+	return strings.HasPrefix(req.Header("Content-Type"), "multipart/form-data")
+}
+
+// parseMultipart parses the multipart body, spooling anything over the
+// configured upload memory to temporary files
+func (req *Request) parseMultipart() error {
+	if req.R.MultipartForm != nil {
+		return nil
+	}
+	return req.R.ParseMultipartForm(req.uploadMemory)
+}
+
+// cleanup removes any temporary files created while parsing the request
+func (req *Request) cleanup() {
+	if req.R.MultipartForm != nil {
+		req.R.MultipartForm.RemoveAll()
+	}
+}
@@ -0,0 +1,144 @@
+package router
+
+import (
+	"github.com/gschier/hemlock/interfaces"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+type resourceAction struct {
+	name    string
+	method  string
+	methods []string
+	member  bool
+	suffix  string
+	api     bool
+}
+
+// resourceActions are the conventional controller methods, in the order
+// their routes are added. Create comes before Show so /photos/create isn't
+// taken as a photo ID.
+var resourceActions = []resourceAction{
+	{name: "index", method: "Index", methods: []string{http.MethodGet}, api: true},
+	{name: "create", method: "Create", methods: []string{http.MethodGet}, suffix: "/create"},
+	{name: "store", method: "Store", methods: []string{http.MethodPost}, api: true},
+	{name: "show", method: "Show", methods: []string{http.MethodGet}, member: true, api: true},
+	{name: "edit", method: "Edit", methods: []string{http.MethodGet}, member: true, suffix: "/edit"},
+	{name: "update", method: "Update", methods: []string{http.MethodPut, http.MethodPatch}, member: true, api: true},
+	{name: "destroy", method: "Destroy", methods: []string{http.MethodDelete}, member: true, api: true},
+}
+
+func (router *Router) Resource(name string, controller interface{}, options ...interfaces.ResourceOptions) {
+	router.resource(name, controller, false, options)
+}
+
+func (router *Router) APIResource(name string, controller interface{}, options ...interfaces.ResourceOptions) {
+	router.resource(name, controller, true, options)
+}
+
+func (router *Router) resource(name string, controller interface{}, api bool, options []interfaces.ResourceOptions) {
+	var opts interfaces.ResourceOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	segments := strings.Split(name, ".")
+	last := segments[len(segments)-1]
+
+	// Nested resources are prefixed with their parents, like
+	// /posts/{post}/comments
+	var parentPath string
+	for _, s := range segments[:len(segments)-1] {
+		parentPath += "/" + s + "/{" + resourceParam(s, opts) + "}"
+	}
+
+	collectionPath := parentPath + "/" + last
+	memberPath := collectionPath + "/{" + resourceParam(last, opts) + "}"
+	memberName := name
+	if opts.Shallow {
+		memberPath = "/" + last + "/{" + resourceParam(last, opts) + "}"
+		memberName = last
+	}
+
+	// Methods are looked up on a pointer so controllers passed by value
+	// can still have pointer receivers
+	c := reflect.ValueOf(controller)
+	typeName := c.Type().String()
+	if c.Kind() != reflect.Ptr {
+		p := reflect.New(c.Type())
+		p.Elem().Set(c)
+		c = p
+	}
+
+	added := 0
+	for _, action := range resourceActions {
+		if (api && !action.api) || !includesAction(action.name, opts) {
+			continue
+		}
+
+		// Controllers only need the methods for the routes they want
+		method := c.MethodByName(action.method)
+		if !method.IsValid() {
+			continue
+		}
+
+		uri, routeName := collectionPath, name
+		if action.member {
+			uri, routeName = memberPath, memberName
+		}
+
+		route := router.newRoute()
+		route.handlerName = typeName + "." + action.method
+		route.Middleware(opts.Middleware...)
+		route.Match(action.methods, uri+action.suffix, method.Interface()).Name(routeName + "." + action.name)
+		added++
+	}
+
+	if added == 0 {
+		log.Panicf("Resource %s: %s has none of the methods for its routes", name, typeName)
+	}
+}
+
+func includesAction(action string, opts interfaces.ResourceOptions) bool {
+	if len(opts.Only) > 0 && !containsString(opts.Only, action) {
+		return false
+	}
+	return !containsString(opts.Except, action)
+}
+
+// resourceParam returns the route parameter for a resource, which is its
+// singular name unless it's been overridden
+func resourceParam(resource string, opts interfaces.ResourceOptions) string {
+	if p, ok := opts.Parameters[resource]; ok {
+		return p
+	}
+	return singular(resource)
+}
+
+// singular handles the common English plurals. Use
+// ResourceOptions.Parameters for anything else.
+func singular(s string) string {
+	switch {
+	case strings.HasSuffix(s, "ies"):
+		return strings.TrimSuffix(s, "ies") + "y"
+	case strings.HasSuffix(s, "sses"), strings.HasSuffix(s, "xes"),
+		strings.HasSuffix(s, "ches"), strings.HasSuffix(s, "shes"):
+		return strings.TrimSuffix(s, "es")
+	case strings.HasSuffix(s, "ss"):
+		return s
+	case strings.HasSuffix(s, "s"):
+		return strings.TrimSuffix(s, "s")
+	}
+	return s
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
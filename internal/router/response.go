@@ -1,6 +1,7 @@
 package router
 
 import (
+	"github.com/gschier/hemlock"
 	"github.com/gschier/hemlock/interfaces"
 	"github.com/gschier/hemlock/internal/templates"
 	"net/http"
@@ -12,6 +13,7 @@ type Response struct {
 	renderer       *templates.Renderer
 	hasWrittenData bool
 	router         *Router
+	app            *hemlock.Application
 
 	status  int
 	headers *http.Header
@@ -22,12 +24,14 @@ func newResponse(
 	req *Request,
 	renderer *templates.Renderer,
 	router *Router,
+	app *hemlock.Application,
 ) *Response {
 	return &Response{
 		W:        w,
 		req:      req,
 		renderer: renderer,
 		router:   router,
+		app:      app,
 	}
 }
 
@@ -54,6 +58,30 @@ func (res *Response) View(name, layout string, data map[string]interface{}) inte
 	return res.newResult().View(name, layout, data)
 }
 
+func (res *Response) Negotiate(data interface{}) interfaces.Result {
+	return res.newResult().Negotiate(data)
+}
+
+func (res *Response) NegotiateView(name, layout string, data interface{}) interfaces.Result {
+	return res.newResult().NegotiateView(name, layout, data)
+}
+
+func (res *Response) JSON(status int, v interface{}) interfaces.Result {
+	return res.newResult().JSON(status, v)
+}
+
+func (res *Response) JSONStream(status int, next func() (interface{}, bool, error)) interfaces.Result {
+	return res.newResult().JSONStream(status, next)
+}
+
+func (res *Response) Stream(fn func(w interfaces.EventWriter) error) interfaces.Result {
+	return res.newResult().Stream(fn)
+}
+
+func (res *Response) SSE(events <-chan interfaces.Event) interfaces.Result {
+	return res.newResult().SSE(events)
+}
+
 func (res *Response) Data(data interface{}) interfaces.Result {
 	return res.newResult().Data(data)
 }
@@ -79,5 +107,5 @@ func (res *Response) End() interfaces.Result {
 }
 
 func (res *Response) newResult() interfaces.Result {
-	return newResult(res.W, res.req.R, res.status, res.renderer, res.router)
+	return newResult(res.W, res.req.R, res.status, res.renderer, res.router, res.app)
 }
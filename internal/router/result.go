@@ -1,21 +1,32 @@
 package router
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/exceptions"
 	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/debug"
 	"github.com/gschier/hemlock/internal/templates"
+	"github.com/gschier/hemlock/negotiate"
 	"io"
 	"mime"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 )
 
+// jsonStreamFlushEvery is how many items JSONStream writes between flushes
+const jsonStreamFlushEvery = 100
+
+var jsonpCallbackRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$.]*$`)
+
 type Result struct {
 	w http.ResponseWriter
 	r *http.Request
@@ -23,6 +34,7 @@ type Result struct {
 	status   int
 	renderer *templates.Renderer
 	router   *Router
+	app      *hemlock.Application
 	error    error
 
 	// hasSentHeaders signifies that data has already been written
@@ -37,8 +49,9 @@ func newResult(
 	status int,
 	renderer *templates.Renderer,
 	router *Router,
+	app *hemlock.Application,
 ) interfaces.Result {
-	return &Result{w: w, r: r, status: status, renderer: renderer, router: router}
+	return &Result{w: w, r: r, status: status, renderer: renderer, router: router, app: app}
 }
 
 func (r *Result) Redirect(uri string, code int) interfaces.Result {
@@ -47,7 +60,11 @@ func (r *Result) Redirect(uri string, code int) interfaces.Result {
 }
 
 func (r *Result) RedirectRoute(name string, params map[string]string, code int) interfaces.Result {
-	return r.Redirect(r.router.Route(name, params), code)
+	u, err := r.router.RouteURL(name, params)
+	if err != nil {
+		return r.Error(err)
+	}
+	return r.Redirect(u, code)
 }
 
 func (r *Result) Error(err error) interfaces.Result {
@@ -56,16 +73,101 @@ func (r *Result) Error(err error) interfaces.Result {
 		return r
 	}
 
-	r.defaultStatus(http.StatusInternalServerError)
-	r.w.Header().Set("Content-Type", "text/plain")
+	r.error = err
+
+	// Keep a status that was set for the error, like
+	// res.Status(400).Error(err)
+	var httpErr *hemlock.HTTPError
+	if !errors.As(err, &httpErr) && r.status >= http.StatusBadRequest {
+		err = &hemlock.HTTPError{Status: r.status, Cause: err}
+	}
+
+	req := r.request()
+	handler := r.exceptionHandler()
+	handler.Report(req, err)
+
+	// It's too late to respond with anything else
+	if r.hasSentHeaders {
+		return r
+	}
+
+	// Show developers what went wrong instead of the usual error page
+	if r.app.IsDev() && hemlock.AsHTTPError(err).Status >= http.StatusInternalServerError && !req.WantsJSON() {
+		return r.debugPage(req, err)
+	}
+
+	res := newResponse(r.w, req, r.renderer, r.router, r.app)
+	result := handler.Render(req, res, err)
+	if result == nil {
+		return r
+	}
+
+	// Keep the error so middleware can see what the response was for
+	if rr, ok := result.(*Result); ok {
+		rr.error = err
+	}
+
+	return result
+}
+
+func (r *Result) debugPage(req *Request, err error) interfaces.Result {
+	route := req.RouteName()
+	if current := mux.CurrentRoute(r.r); route == "" && current != nil {
+		route, _ = current.GetPathTemplate()
+	}
+
+	page := debug.NewPage(err, r.r, route, mux.Vars(r.r), r.app.Bindings())
+
+	r.status = hemlock.AsHTTPError(err).Status
+	r.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r.flushHeaders()
+	r.hasSentData = true
+
+	if err := page.Render(r.w); err != nil {
+		r.exceptionHandler().Report(req, err)
+	}
+
+	return r
+}
+
+// request returns the Request bound for the callback, or a new one if the
+// error happened before it was bound
+func (r *Result) request() *Request {
+	if r.app.Has(new(Request)) {
+		return r.app.Make(new(Request)).(*Request)
+	}
+	return newRequest(r.r)
+}
+
+// exceptionHandler returns the app's ExceptionHandler, or the default one
+// if it has none
+func (r *Result) exceptionHandler() interfaces.ExceptionHandler {
+	var handler interfaces.ExceptionHandler
+	if r.app.Has(&handler) {
+		r.app.Resolve(&handler)
+		return handler
+	}
+	return exceptions.New(r.app)
+}
 
-	// Log error if there was one
-	// TODO: Make this better
-	fmt.Printf("[router] Error: %v\n", err)
+func (r *Result) StatusCode() int {
+	if p := pipelineFromContext(r.r.Context()); p != nil && p.writer.Status() != 0 {
+		return p.writer.Status()
+	}
+
+	if r.status != 0 {
+		return r.status
+	}
+
+	return http.StatusOK
+}
 
-	// Respond with error page
-	// TODO: Use user-provided error page if available
-	return r.Data("Internal Server Error")
+func (r *Result) Header() http.Header {
+	return r.w.Header()
+}
+
+func (r *Result) Err() error {
+	return r.error
 }
 
 func (r *Result) Sprintf(format string, a ...interface{}) interfaces.Result {
@@ -73,6 +175,10 @@ func (r *Result) Sprintf(format string, a ...interface{}) interfaces.Result {
 }
 
 func (r *Result) View(name, layout string, data map[string]interface{}) interfaces.Result {
+	return r.renderView(name, layout, data)
+}
+
+func (r *Result) renderView(name, layout string, data interface{}) interfaces.Result {
 	// Set content type based on extension of template
 	ext := filepath.Ext(name)
 	r.w.Header().Set("Content-Type", mime.TypeByExtension(ext))
@@ -80,8 +186,10 @@ func (r *Result) View(name, layout string, data map[string]interface{}) interfac
 	r.flushHeaders()
 	r.hasSentData = true
 
+	// Funcs use the request's app so they can make use of request-scoped
+	// services like the current user
 	ctx := r.getRenderContext(data)
-	err := r.renderer.RenderTemplate(r.w, name, layout, ctx)
+	err := r.renderer.RenderTemplate(r.w, name, layout, ctx, r.app)
 	if err != nil {
 		return r.Error(err)
 	}
@@ -94,33 +202,213 @@ func (r *Result) Data(data interface{}) interfaces.Result {
 		return r.Error(err)
 	}
 
+	var body []byte
+	switch v := data.(type) {
+	case []byte:
+		body = v
+	case io.Reader:
+		r.flushHeaders()
+		r.hasSentData = true
+		io.Copy(r.w, v)
+		return r
+	default:
+		// Encode JSON before writing anything so the Content-Type can be
+		// set and errors can still be reported
+		if isJSONValue(data) {
+			encoded, err := r.encodeJSON(data)
+			if err != nil {
+				return r.Error(err)
+			}
+			r.defaultHeader("Content-Type", "application/json")
+			body = encoded
+		} else {
+			body = []byte(fmt.Sprintf("%v", data))
+		}
+	}
+
 	r.flushHeaders()
 
 	r.hasSentData = true
+	r.w.Write(body)
 
-	if v, ok := data.([]byte); ok {
-		r.w.Write(v)
-	} else if v, ok := data.(io.Reader); ok {
-		io.Copy(r.w, v)
+	return r
+}
+
+func (r *Result) JSON(status int, v interface{}) interfaces.Result {
+	if envelope := r.httpConfig().JSONEnvelope; envelope != "" && status < http.StatusBadRequest {
+		v = map[string]interface{}{envelope: v}
+	}
+
+	body, err := r.encodeJSON(v)
+	if err != nil {
+		return r.Error(err)
+	}
+
+	if status != 0 {
+		r.status = status
+	}
+
+	if callback := r.jsonpCallback(); callback != "" {
+		r.w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		r.w.Header().Set("X-Content-Type-Options", "nosniff")
+		body = []byte("/**/ typeof " + callback + " === 'function' && " +
+			callback + "(" + strings.TrimSpace(string(body)) + ");")
 	} else {
-		dataType := reflect.TypeOf(data)
-		if dataType.Kind() == reflect.Ptr {
-			dataType = dataType.Elem()
+		r.w.Header().Set("Content-Type", "application/json")
+	}
+
+	return r.Data(body)
+}
+
+func (r *Result) JSONStream(status int, next func() (interface{}, bool, error)) interfaces.Result {
+	if status != 0 {
+		r.status = status
+	}
+
+	r.w.Header().Set("Content-Type", "application/json")
+	r.flushHeaders()
+	r.hasSentData = true
+
+	prefix, suffix := "[", "]"
+	if envelope := r.httpConfig().JSONEnvelope; envelope != "" && r.status < http.StatusBadRequest {
+		key, _ := json.Marshal(envelope)
+		prefix, suffix = "{"+string(key)+":[", "]}"
+	}
+
+	flusher, _ := r.w.(http.Flusher)
+
+	io.WriteString(r.w, prefix)
+	for i := 0; ; i++ {
+		var b []byte
+		item, ok, err := next()
+		if err == nil && ok {
+			b, err = json.Marshal(item)
 		}
 
-		if dataType.Kind() == reflect.Struct {
-			e := json.NewEncoder(r.w)
-			e.Encode(data)
-		} else {
-			v := []byte(fmt.Sprintf("%v", data))
-			r.w.Write(v)
+		// Leave the array unterminated so the client sees it was cut off
+		if err != nil {
+			r.error = err
+			r.exceptionHandler().Report(r.request(), err)
+			return r
+		}
+
+		if !ok {
+			break
+		}
+
+		if i > 0 {
+			io.WriteString(r.w, ",")
+		}
+		r.w.Write(b)
+
+		if flusher != nil && i%jsonStreamFlushEvery == jsonStreamFlushEvery-1 {
+			flusher.Flush()
 		}
 	}
+	io.WriteString(r.w, suffix)
 
 	return r
 }
 
-func (r *Result) getRenderContext(data map[string]interface{}) interface{} {
+// encodeJSON encodes v, indenting it in development
+func (r *Result) encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	if r.app.IsDev() {
+		e.SetIndent("", "  ")
+	}
+
+	err := e.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// jsonpCallback returns the JSONP callback requested, if JSONP is enabled
+// and the callback is a valid identifier
+func (r *Result) jsonpCallback() string {
+	param := r.httpConfig().JSONPCallback
+	if param == "" {
+		return ""
+	}
+
+	callback := r.r.URL.Query().Get(param)
+	if !jsonpCallbackRegexp.MatchString(callback) {
+		return ""
+	}
+
+	return callback
+}
+
+func (r *Result) httpConfig() *hemlock.HTTPConfig {
+	if r.app.Config.HTTP == nil {
+		return &hemlock.HTTPConfig{}
+	}
+	return r.app.Config.HTTP
+}
+
+func (r *Result) Negotiate(data interface{}) interfaces.Result {
+	return r.NegotiateView("", "", data)
+}
+
+func (r *Result) NegotiateView(name, layout string, data interface{}) interfaces.Result {
+	encoders := r.encoders().For(data)
+
+	var offers []string
+	if name != "" {
+		offers = append(offers, "text/html")
+	}
+	for _, enc := range encoders {
+		offers = append(offers, enc.MediaType())
+	}
+
+	r.w.Header().Add("Vary", "Accept")
+
+	mediaType := negotiate.Match(r.r.Header.Get("Accept"), offers)
+	if mediaType == "" {
+		r.status = http.StatusNotAcceptable
+		r.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		return r.Data(http.StatusText(http.StatusNotAcceptable))
+	}
+
+	if mediaType == "text/html" {
+		return r.renderView(name, layout, data)
+	}
+
+	for _, enc := range encoders {
+		if enc.MediaType() != mediaType {
+			continue
+		}
+
+		// Encode before writing anything so errors can still be reported
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, data); err != nil {
+			return r.Error(err)
+		}
+
+		contentType := mediaType
+		if strings.HasPrefix(contentType, "text/") {
+			contentType += "; charset=utf-8"
+		}
+		r.w.Header().Set("Content-Type", contentType)
+
+		return r.Data(buf.Bytes())
+	}
+
+	return r
+}
+
+// encoders returns the app's encoders, or the defaults if it has none
+func (r *Result) encoders() *negotiate.Encoders {
+	if r.app.Has(new(negotiate.Encoders)) {
+		return r.app.Make(new(negotiate.Encoders)).(*negotiate.Encoders)
+	}
+	return negotiate.Default()
+}
+
+func (r *Result) getRenderContext(data interface{}) interface{} {
 	var config hemlock.Config
 	var router interfaces.Router
 	r.router.app.Resolve(&config, &router)
@@ -181,3 +469,26 @@ func (r *Result) applyDefaultHeaders() {
 	r.defaultHeader("Server", "Hemlock/"+hemlock.Version())
 	r.defaultHeader("Content-Type", mime.TypeByExtension(filepath.Ext(r.r.URL.Path)))
 }
+
+// isJSONValue returns whether Data should encode the value as JSON
+func isJSONValue(data interface{}) bool {
+	if _, ok := data.([]byte); ok {
+		return false
+	}
+
+	t := reflect.TypeOf(data)
+	if t == nil {
+		return false
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+
+	return false
+}
@@ -3,14 +3,39 @@ package router
 import (
 	"github.com/gorilla/mux"
 	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/exceptions"
 	"github.com/gschier/hemlock/interfaces"
 	"github.com/gschier/hemlock/internal/templates"
+	"github.com/gschier/hemlock/validation"
 	"net/http"
+	"reflect"
+	"strconv"
 )
 
 type Route struct {
 	route  *mux.Route
 	router *Router
+
+	maxBodySize int64
+	maxFileSize int64
+	csrfExempt  bool
+	modelParams []string
+
+	// status is the default response status, used by fallback routes
+	status int
+
+	middlewares []*middlewareContainer
+	chain       middlewareChain
+
+	// callback is kept so the route can be described. handlerName
+	// overrides the name of its function.
+	callback    interface{}
+	handlerName string
+
+	// summary, responses and undocumented describe the route for OpenAPI
+	summary      string
+	responses    []routeResponse
+	undocumented bool
 }
 
 func NewRoute(router *Router, route *mux.Route) *Route {
@@ -30,7 +55,7 @@ func (r *Route) View(uri, view, layout string, data map[string]interface{}) inte
 }
 
 func (r *Route) Callback(callback interfaces.Callback) interfaces.Route {
-	r.route.HandlerFunc(r.wrap(callback))
+	r.route.Handler(r.handler(callback))
 	return r
 }
 
@@ -98,12 +123,51 @@ func (r *Route) Name(n string) interfaces.Route {
 	return r
 }
 
+func (r *Route) MaxBodySize(bytes int64) interfaces.Route {
+	r.maxBodySize = bytes
+	return r
+}
+
+func (r *Route) MaxFileSize(bytes int64) interfaces.Route {
+	r.maxFileSize = bytes
+	return r
+}
+
+func (r *Route) CSRFExempt() interfaces.Route {
+	r.csrfExempt = true
+	return r
+}
+
+func (r *Route) ModelParams(params ...string) interfaces.Route {
+	r.modelParams = params
+	return r
+}
+
+// IsCSRFExempt returns whether CSRF verification is disabled for the route
+func (r *Route) IsCSRFExempt() bool {
+	return r.csrfExempt
+}
+
+func (r *Route) Middleware(m ...interface{}) interfaces.Route {
+	for _, m := range m {
+		r.middlewares = append(r.middlewares, newMiddlewareContainer(m))
+	}
+	r.chain.reset()
+	return r
+}
+
 func (r *Route) Use(m ...interfaces.Middleware) {
-	r.router.Use(m...)
+	for _, m := range m {
+		r.middlewares = append(r.middlewares, newMiddlewareContainer(m))
+	}
+	r.chain.reset()
 }
 
 func (r *Route) UseG(m ...func(http.Handler) http.Handler) {
-	r.router.UseG(m...)
+	for _, m := range m {
+		r.middlewares = append(r.middlewares, newMiddlewareContainer(m))
+	}
+	r.chain.reset()
 }
 
 func (r *Route) With(m ...interfaces.Middleware) interfaces.Route {
@@ -114,8 +178,21 @@ func (r *Route) WithG(m ...func(http.Handler) http.Handler) interfaces.Route {
 	return r.router.WithG(m...)
 }
 
+// Group calls fn with a router for routes nested inside this one. They
+// share its host, prefix and middleware.
 func (r *Route) Group(fn func(router interfaces.Router)) {
-	fn(r.router.fork())
+	g := r.router.fork(r.route)
+	g.middlewares = append(g.middlewares, r.middlewares...)
+	fn(g)
+}
+
+// handler runs the route's middleware before the callback
+func (r *Route) handler(callback interface{}) http.Handler {
+	r.callback = callback
+	fn := r.wrap(callback)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.router.runMiddleware(r.middlewares, &r.chain, w, req, fn)
+	})
 }
 
 func (r *Route) wrap(callback interface{}) http.HandlerFunc {
@@ -125,28 +202,162 @@ func (r *Route) wrap(callback interface{}) http.HandlerFunc {
 		var renderer templates.Renderer
 		newApp.Resolve(&renderer)
 
+		maxBodySize, maxFileSize, uploadMemory := r.limits()
+
+		// The body was limited when the request was first matched
+		body, _ := r2.Body.(*limitedBody)
+
 		req := newRequest(r2)
-		res := newResponse(w, req, &renderer, r.router)
+		res := newResponse(w, req, &renderer, r.router, newApp)
+
+		if r.status != 0 {
+			res.Status(r.status)
+		}
+
+		// Keep the status set by middleware before calling next
+		p := pipelineFromContext(r2.Context())
+		if p != nil && p.status != 0 {
+			res.Status(p.status)
+		}
+
+		if uploadMemory > 0 {
+			req.uploadMemory = uploadMemory
+		}
+
+		if maxBodySize > 0 && r2.ContentLength > maxBodySize {
+			abort(res, http.StatusRequestEntityTooLarge, "")
+			return
+		}
+
+		// Parse uploads up front so size limits are enforced before the
+		// callback runs and temp files can be removed when we're done
+		if req.isMultipart() {
+			defer req.cleanup()
+			err := req.parseMultipart()
+			if body != nil && body.exceeded {
+				abort(res, http.StatusRequestEntityTooLarge, "")
+				return
+			} else if err != nil {
+				abort(res, http.StatusBadRequest, "")
+				return
+			}
+
+			for _, headers := range req.R.MultipartForm.File {
+				for _, h := range headers {
+					if maxFileSize > 0 && h.Size > maxFileSize {
+						abort(res, http.StatusRequestEntityTooLarge, "")
+						return
+					}
+				}
+			}
+		}
 
 		newApp.Instance(req)
 		newApp.Instance(res)
 
-		extraArgs := make([]interface{}, 0)
-		for _, v := range mux.Vars(r2) {
-			extraArgs = append(extraArgs, v)
+		// Bind anything middleware attached to the request
+		for _, v := range instancesFromContext(r2.Context()) {
+			newApp.Instance(v)
+		}
+
+		// Panics become errors so they go through the ExceptionHandler
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				setResult(r2, res.Error(exceptions.NewPanicError(v)))
+			}
+		}()
+
+		results, err := newApp.ResolveIntoWith(callback, positionalResolver(callback, r2, r.argResolver(req, newApp)))
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			invalid(req, res, errs)
+			return
+		} else if err != nil {
+			setResult(r2, res.Error(err))
+			return
 		}
 
-		results := newApp.ResolveInto(callback, extraArgs...)
 		if len(results) != 1 {
-			panic("Route did not return a value. Got " + string(len(results)))
+			panic("Route did not return a value. Got " + strconv.Itoa(len(results)))
+		}
+
+		if result, ok := results[0].(interfaces.Result); ok {
+			setResult(r2, result)
+		}
+	}
+}
+
+// argResolver resolves callback arguments that come from the request
+// rather than the container, like route models and structs tagged for
+// binding or embedding hemlock.Input.
+// Arguments with `validate` tags are validated once they're bound.
+func (r *Route) argResolver(req *Request, app *hemlock.Application) func(reflect.Type) (reflect.Value, bool, error) {
+	models := 0
+	return func(t reflect.Type) (reflect.Value, bool, error) {
+		if binder := r.router.modelBinder(t); binder != nil {
+			param := binder.param
+			if models < len(r.modelParams) {
+				param = r.modelParams[models]
+			}
+			models++
+
+			if v, ok, err := binder.bind(param, req.R, app); ok || err != nil {
+				return v, ok, err
+			}
+		}
+
+		// Anything the container has, like the user auth.Required binds,
+		// comes from there so it can't be spoofed by the request
+		if !isBindable(t) || t.Kind() == reflect.Ptr && app.Has(reflect.New(t.Elem()).Interface()) {
+			return reflect.Value{}, false, nil
+		}
+
+		v, err := newTaggedValue(t, req.bind)
+		if err != nil {
+			return v, false, err
+		}
+
+		if hasTag(t, "validate") {
+			err = validatorFor(app).Validate(v.Interface(), validation.Locales(req.Header("Accept-Language"))...)
+			if err != nil {
+				return reflect.Value{}, false, err
+			}
 		}
+
+		return v, true, nil
+	}
+}
+
+// abort responds with an error without calling the route callback. If
+// message is empty, the status text is used.
+func abort(res *Response, status int, message string) {
+	setResult(res.req.R, res.Error(hemlock.NewHTTPError(status, message)))
+}
+
+// limits returns the body, file and upload memory limits for the route,
+// falling back to the app's HTTP config
+func (r *Route) limits() (maxBodySize, maxFileSize, uploadMemory int64) {
+	if c := r.router.app.Config.HTTP; c != nil {
+		maxBodySize, maxFileSize, uploadMemory = c.MaxBodySize, c.MaxFileSize, c.UploadMemory
+	}
+
+	if r.maxBodySize != 0 {
+		maxBodySize = r.maxBodySize
+	}
+
+	if r.maxFileSize != 0 {
+		maxFileSize = r.maxFileSize
 	}
+
+	return maxBodySize, maxFileSize, uploadMemory
 }
 
 func (r *Route) assignCallback(methods []string, uri string, callback interface{}) interfaces.Route {
 	if methods != nil {
 		r.Methods(methods...)
 	}
-	r.route.Path(uri).HandlerFunc(r.wrap(callback))
+	r.route.Path(uri).Handler(r.handler(callback))
 	return r
 }
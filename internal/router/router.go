@@ -1,11 +1,11 @@
 package router
 
 import (
+	"fmt"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/gschier/hemlock"
 	"github.com/gschier/hemlock/interfaces"
-	"github.com/gschier/hemlock/internal/templates"
 	"log"
 	"mime"
 	"net/http"
@@ -13,20 +13,30 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 )
 
-type middlewareContainer struct {
-	hemlock interfaces.Middleware
-	native  func(http.Handler) http.Handler
-}
-
 type Router struct {
 	app          *hemlock.Application
 	mux          *mux.Router
 	middlewares  []*middlewareContainer
+	chain        middlewareChain
 	didSetupURLs bool
 	root         bool
+	routes       *routeRegistry
+
+	// top is the root router this one was forked from, or itself, and
+	// parent is the router it was forked from directly
+	top    *Router
+	parent *Router
+
+	// aliases, priority and models are only used on the top router
+	aliases    map[string][]interface{}
+	priority   []string
+	models     map[reflect.Type]*modelBinder
+	aliasMutex sync.RWMutex
 }
 
 func NewRouter(app *hemlock.Application) *Router {
@@ -34,7 +44,13 @@ func NewRouter(app *hemlock.Application) *Router {
 }
 
 func NewRouterWithMux(app *hemlock.Application, m *mux.Router, isRoot bool) *Router {
-	router := &Router{app: app, mux: m}
+	return newRouterWithMux(app, m, isRoot, newRouteRegistry())
+}
+
+func newRouterWithMux(app *hemlock.Application, m *mux.Router, isRoot bool, routes *routeRegistry) *Router {
+	router := &Router{app: app, mux: m, root: isRoot, routes: routes}
+	router.top = router
+	routes.addRouter(router)
 
 	// Redirect slashes
 	router.mux.StrictSlash(true)
@@ -48,32 +64,20 @@ func NewRouterWithMux(app *hemlock.Application, m *mux.Router, isRoot bool) *Rou
 
 		// Add logging middleware
 		if app.IsDev() {
-			router.UseG(func(next http.Handler) http.Handler {
-				return handlers.LoggingHandler(os.Stdout, next)
-			})
+			router.UseG(logRequests)
 		}
 
 		if !app.IsDev() {
-			// Add caching middleware
-			router.Use(func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
-				ext := filepath.Ext(req.Path())
-				if ext == ".css" || ext == ".js" {
-					res.Header("Cache-Control", "public, max-age=7200")
-				}
-				return next(req, res)
-			})
-			// Add HTTP redirect middleware
-			router.Use(func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
-				if req.Header("X-Forwarded-Proto") == "http" {
-					newUrl := "https://" + req.Host() + req.URL().String()
-					return res.Redirect(newUrl, http.StatusFound)
-				} else {
-					return next(req, res)
-				}
-			})
+			router.Use(cacheAssets)
+			router.Use(redirectHTTP)
 		}
 	}
 
+	// Limit request bodies before any middleware gets a chance to read them
+	if isRoot {
+		router.mux.Use(router.limitBody)
+	}
+
 	// Add main handler to call middleware
 	router.mux.Use(func(next http.Handler) http.Handler {
 		// Not sure why this is needed but `next` is `nil` when executing middleware on a 404
@@ -82,35 +86,49 @@ func NewRouterWithMux(app *hemlock.Application, m *mux.Router, isRoot bool) *Rou
 		}
 
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			router.nextCombinedMiddleware(0, w, r, func(w2 http.ResponseWriter, r2 *http.Request) {
-				next.ServeHTTP(w2, r2)
-			})
+			router.runMiddleware(router.middlewares, &router.chain, w, r, next.ServeHTTP)
 		})
 	})
 
+	if isRoot {
+		router.Alias("signed", router.signed)
+		router.NotFound(func(res interfaces.Response) interfaces.Result {
+			return res.Error(hemlock.NotFound())
+		})
+		router.MethodNotAllowed(func(res interfaces.Response) interfaces.Result {
+			return res.Error(hemlock.MethodNotAllowed())
+		})
+	}
+
 	// Add static handler
-	u, err := url.Parse(app.Config.PublicPrefix)
-	if err == nil { // Make sure PublicPrefix is a valid path or URL
-		publicPrefixPath := u.Path
-		router.Prefix(publicPrefixPath).Methods(http.MethodGet).Callback(
-			func(req interfaces.Request, res interfaces.Response) interfaces.Result {
-				p := req.Path()
-				p = strings.TrimPrefix(p, publicPrefixPath)
-				cwd, _ := os.Getwd()
-				fullPath := filepath.Join(cwd, app.Config.PublicDirectory, p)
-				s, err := os.Stat(fullPath)
-				if err != nil || s.IsDir() {
-					return res.Status(404).Data("Resource not found")
-				}
-				f, err := os.Open(fullPath)
-
-				ext := filepath.Ext(fullPath)
-				return res.
-					Header("Access-Control-Allow-Origin", "*").
-					Header("Content-Type", mime.TypeByExtension(ext)).
-					Data(f)
-			},
-		)
+	if isRoot {
+		u, err := url.Parse(app.Config.PublicPrefix)
+		if err == nil { // Make sure PublicPrefix is a valid path or URL
+			publicPrefixPath := u.Path
+			router.Prefix(publicPrefixPath).Methods(http.MethodGet).Callback(
+				func(req interfaces.Request, res interfaces.Response) interfaces.Result {
+					p := req.Path()
+					p = strings.TrimPrefix(p, publicPrefixPath)
+					cwd, _ := os.Getwd()
+					fullPath := filepath.Join(cwd, app.Config.PublicDirectory, p)
+					s, err := os.Stat(fullPath)
+					if err != nil || s.IsDir() {
+						return res.Error(hemlock.NotFound())
+					}
+					f, err := os.Open(fullPath)
+					if err != nil {
+						return res.Error(hemlock.NotFound())
+					}
+					defer f.Close()
+
+					ext := filepath.Ext(fullPath)
+					return res.
+						Header("Access-Control-Allow-Origin", "*").
+						Header("Content-Type", mime.TypeByExtension(ext)).
+						Data(f)
+				},
+			)
+		}
 	}
 
 	return router
@@ -185,55 +203,125 @@ func (router *Router) Host(hostname string) interfaces.Route {
 }
 
 func (router *Router) With(m ...interfaces.Middleware) interfaces.Route {
-	newRouter := router.fork()
-	newRouter.Use(m...)
-	return newRouter.newRoute()
+	route := router.newRoute()
+	route.Use(m...)
+	return route
 }
 
 func (router *Router) WithG(m ...func(http.Handler) http.Handler) interfaces.Route {
-	newRouter := router.fork()
-	newRouter.UseG(m...)
-	return newRouter.newRoute()
+	route := router.newRoute()
+	route.UseG(m...)
+	return route
+}
+
+func (router *Router) Middleware(m ...interface{}) interfaces.Route {
+	return router.newRoute().Middleware(m...)
 }
 
 func (router *Router) Use(m ...interfaces.Middleware) {
 	for _, m := range m {
-		router.middlewares = append(router.middlewares, &middlewareContainer{
-			hemlock: m,
-		})
+		router.middlewares = append(router.middlewares, newMiddlewareContainer(m))
 	}
+	router.chain.reset()
 }
 
 func (router *Router) UseG(m ...func(http.Handler) http.Handler) {
 	for _, m := range m {
-		router.middlewares = append(router.middlewares, &middlewareContainer{
-			native: m,
-		})
+		router.middlewares = append(router.middlewares, newMiddlewareContainer(m))
 	}
+	router.chain.reset()
 }
 
 // Handler returns the HTTP handler
 func (router *Router) Handler() http.Handler {
-	return router.mux
+	router.top.resolveAll()
+	return withPipeline(router.top.mux)
 }
 
 func (router *Router) Route(name string, params interfaces.RouteParams) string {
+	u, err := router.RouteURL(name, params)
+	if err != nil {
+		log.Panic(err)
+	}
+	return u
+}
+
+func (router *Router) RouteURL(name string, params interfaces.RouteParams, options ...interfaces.URLOptions) (string, error) {
+	var opts interfaces.URLOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
 	r := router.mux.Get(name)
 	if r == nil {
-		log.Panicf("Failed to find route by name '%s'", name)
+		return "", fmt.Errorf("no route named '%s'", name)
 	}
 
+	// Params the route doesn't use go in the query string
+	vars := routeVars(r)
 	args := make([]string, 0)
+	query := make(url.Values)
 	for k, v := range params {
-		args = append(args, k, v)
+		if vars[k] {
+			args = append(args, k, v)
+		} else {
+			query.Set(k, v)
+		}
 	}
 
 	u, err := r.URL(args...)
 	if err != nil {
-		log.Panicf("Failed to get URL name=%s args=%v Error: %s", name, args, err)
+		return "", fmt.Errorf("failed to build URL for route '%s': %v", name, err)
+	}
+
+	// Keep queries the route matches on, like r.Queries("page", "{page}")
+	for k, v := range u.Query() {
+		query[k] = v
+	}
+
+	// Routes without a host are on the app's URL
+	if u.Host == "" {
+		u, err = url.Parse(router.URL(u.Path))
+		if err != nil {
+			return "", err
+		}
+	} else if base, err := url.Parse(router.app.Config.URL); err == nil && base.Scheme == "https" {
+		u.Scheme = base.Scheme
 	}
 
-	return router.URL(u.Path)
+	u.RawQuery = query.Encode()
+	u.Fragment = opts.Fragment
+
+	if opts.Relative {
+		u.Scheme, u.Host = "", ""
+	}
+
+	return u.String(), nil
+}
+
+// routeVars returns the names of the variables in a route's host and path
+func routeVars(r *mux.Route) map[string]bool {
+	vars := make(map[string]bool)
+	for _, get := range []func() (string, error){r.GetHostTemplate, r.GetPathTemplate} {
+		tpl, err := get()
+		if err != nil {
+			continue
+		}
+		_, tplVars := parseTemplate(tpl)
+		for _, v := range tplVars {
+			vars[v.name] = true
+		}
+	}
+
+	queries, _ := r.GetQueriesTemplates()
+	for _, q := range queries {
+		_, tplVars := parseTemplate(q)
+		for _, v := range tplVars {
+			vars[v.name] = true
+		}
+	}
+
+	return vars
 }
 
 func (router *Router) URL(p string) string {
@@ -248,40 +336,70 @@ func (router *Router) URL(p string) string {
 	return u.String()
 }
 
-func (router *Router) fork() *Router {
-	return NewRouterWithMux(router.app, router.mux.NewRoute().Subrouter(), false)
+func (router *Router) NotFound(callback interfaces.Callback) {
+	router.top.mux.NotFoundHandler = router.top.fallback(http.StatusNotFound, callback)
 }
 
-func (router *Router) newRoute() *Route {
-	return NewRoute(router, router.mux.NewRoute())
-}
-
-func (router *Router) nextCombinedMiddleware(
-	i int,
-	w http.ResponseWriter,
-	r *http.Request,
-	fn func(w http.ResponseWriter, r *http.Request),
-) {
-	if i == len(router.middlewares) {
-		fn(w, r)
-		return
-	}
+func (router *Router) MethodNotAllowed(callback interfaces.Callback) {
+	router.top.mux.MethodNotAllowedHandler = router.top.fallback(http.StatusMethodNotAllowed, callback)
+}
 
-	m := router.middlewares[i]
-	if m.hemlock != nil {
-		next := func(newReq interfaces.Request, newRes interfaces.Response) interfaces.Result {
-			router.nextCombinedMiddleware(i+1, newRes.(*Response).W, newReq.(*Request).R, fn)
-			return nil
-		}
+// fallback returns a handler for requests that gorilla couldn't match.
+// Those skip the mux's middleware, so the router's is run here instead.
+func (router *Router) fallback(status int, callback interfaces.Callback) http.Handler {
+	route := &Route{router: router, status: status}
+	handler := route.wrap(callback)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.runMiddleware(router.middlewares, &router.chain, w, r, handler)
+	})
+}
+
+// logRequests logs requests to stdout in development
+func logRequests(next http.Handler) http.Handler {
+	return handlers.LoggingHandler(os.Stdout, next)
+}
+
+// cacheAssets lets clients cache CSS and JS
+func cacheAssets(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+	ext := filepath.Ext(req.Path())
+	if ext == ".css" || ext == ".js" {
+		res.Header("Cache-Control", "public, max-age=7200")
+	}
+	return next(req, res)
+}
 
-		var renderer templates.Renderer
-		router.app.Resolve(&renderer)
-		req := newRequest(r)
-		res := newResponse(w, req, &renderer, router)
-		m.hemlock(req, res, next)
+// redirectHTTP redirects requests that reached the proxy over HTTP to HTTPS
+func redirectHTTP(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+	if req.Header("X-Forwarded-Proto") == "http" {
+		newUrl := "https://" + req.Host() + req.URL().String()
+		return res.Redirect(newUrl, http.StatusFound)
 	} else {
-		m.native(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			router.nextCombinedMiddleware(i+1, w, r, fn)
-		})).ServeHTTP(w, r)
+		return next(req, res)
 	}
 }
+
+// fork returns a router for the routes nested inside m
+func (router *Router) fork(m *mux.Route) *Router {
+	r := newRouterWithMux(router.app, m.Subrouter(), false, router.routes)
+	r.top = router.top
+	r.parent = router
+	return r
+}
+
+func (router *Router) newRoute() *Route {
+	r := NewRoute(router, router.mux.NewRoute())
+	router.routes.add(r)
+	return r
+}
+
+// limitBody applies the matched route's maximum body size to the request
+func (router *Router) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := router.RouteFor(r); route != nil {
+			if maxBodySize, _, _ := route.limits(); maxBodySize > 0 {
+				r.Body = newLimitedBody(r.Body, maxBodySize)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Query parameters added to signed URLs
+const (
+	signatureParam = "signature"
+	expiresParam   = "expires"
+)
+
+func (router *Router) SignedRoute(name string, params interfaces.RouteParams, expiresAt time.Time) string {
+	if router.app.Config.Key == "" {
+		log.Panic("Signed URLs require the app Key to be set")
+	}
+
+	u, err := url.Parse(router.Route(name, params))
+	if err != nil {
+		log.Panicf("Failed to parse URL for route '%s': %v", name, err)
+	}
+
+	q := u.Query()
+	if !expiresAt.IsZero() {
+		q.Set(expiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+	}
+	q.Set(signatureParam, router.signature(u.Path, q))
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func (router *Router) HasValidSignature(req interfaces.Request) bool {
+	return router.checkSignature(req.URL()) == nil
+}
+
+// checkSignature returns a 403 HTTPError if the URL was tampered with or
+// has expired
+func (router *Router) checkSignature(u *url.URL) error {
+	// Without a key anyone could sign URLs
+	q := u.Query()
+	signature := q.Get(signatureParam)
+	if signature == "" || router.app.Config.Key == "" || !hmac.Equal([]byte(signature), []byte(router.signature(u.Path, q))) {
+		return hemlock.Forbidden().WithMessage("Invalid signature")
+	}
+
+	if v := q.Get(expiresParam); v != "" {
+		expires, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || time.Now().Unix() > expires {
+			return hemlock.Forbidden().WithMessage("Link expired")
+		}
+	}
+
+	return nil
+}
+
+// signature signs the path and query, leaving out any existing signature.
+// The host isn't signed so links keep working behind proxies.
+func (router *Router) signature(path string, q url.Values) string {
+	unsigned := make(url.Values)
+	for k, v := range q {
+		if k != signatureParam {
+			unsigned[k] = v
+		}
+	}
+
+	key := sha256.Sum256([]byte("hemlock.signed:" + router.app.Config.Key))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(path + "?" + unsigned.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signed is the middleware registered as the "signed" alias
+func (router *Router) signed(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+	if err := router.checkSignature(req.URL()); err != nil {
+		return res.Error(err)
+	}
+	return next(req, res)
+}
@@ -0,0 +1,193 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gschier/hemlock/interfaces"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSSEHeartbeat is used when HTTPConfig.SSEHeartbeat isn't set
+const defaultSSEHeartbeat = 15 * time.Second
+
+func (r *Result) Stream(fn func(w interfaces.EventWriter) error) interfaces.Result {
+	h := r.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+
+	// Stop proxies like nginx from holding events back
+	h.Set("X-Accel-Buffering", "no")
+
+	r.flushHeaders()
+	r.hasSentData = true
+
+	ew := &eventWriter{r: r.r, w: r.w}
+	ew.flush()
+
+	interval := r.httpConfig().SSEHeartbeat
+	if interval == 0 {
+		interval = defaultSSEHeartbeat
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if interval < 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = ew.Comment("heartbeat")
+			case <-stop:
+				return
+			case <-r.r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	err := fn(ew)
+
+	// Nothing may be written once the handler returns
+	close(stop)
+	<-stopped
+
+	// Errors from the client going away aren't worth reporting
+	if err != nil && r.r.Context().Err() == nil {
+		return r.Error(err)
+	}
+
+	return r
+}
+
+func (r *Result) SSE(events <-chan interfaces.Event) interfaces.Result {
+	return r.Stream(func(w interfaces.EventWriter) error {
+		for {
+			select {
+			case <-w.Context().Done():
+				return nil
+			case event, ok := <-events:
+				if !ok {
+					return nil
+				}
+				if err := w.Send(event); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+type eventWriter struct {
+	r     *http.Request
+	w     http.ResponseWriter
+	mutex sync.Mutex
+}
+
+func (ew *eventWriter) Send(event interfaces.Event) error {
+	var buf bytes.Buffer
+	if event.ID != "" {
+		buf.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+
+	if event.Event != "" {
+		buf.WriteString("event: " + singleLine(event.Event) + "\n")
+	}
+
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	if event.Data != nil {
+		data, err := eventData(event.Data)
+		if err != nil {
+			return err
+		}
+
+		// Each line needs its own field, and the client joins them back up
+		for _, line := range strings.Split(data, "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+
+	buf.WriteString("\n")
+	return ew.write(buf.Bytes())
+}
+
+func (ew *eventWriter) Retry(d time.Duration) error {
+	return ew.write([]byte("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n"))
+}
+
+func (ew *eventWriter) Comment(text string) error {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(normalizeNewlines(text), "\n") {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return ew.write(buf.Bytes())
+}
+
+func (ew *eventWriter) LastEventID() string {
+	return ew.r.Header.Get("Last-Event-ID")
+}
+
+func (ew *eventWriter) Context() context.Context {
+	return ew.r.Context()
+}
+
+func (ew *eventWriter) write(b []byte) error {
+	if err := ew.r.Context().Err(); err != nil {
+		return err
+	}
+
+	ew.mutex.Lock()
+	defer ew.mutex.Unlock()
+
+	if _, err := ew.w.Write(b); err != nil {
+		return err
+	}
+	ew.flush()
+
+	return nil
+}
+
+// flush sends everything written so far through any compression and the
+// pipeline's buffer
+func (ew *eventWriter) flush() {
+	if f, ok := ew.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// eventData returns strings and bytes as they are and anything else as
+// JSON
+func eventData(data interface{}) (string, error) {
+	switch d := data.(type) {
+	case string:
+		return normalizeNewlines(d), nil
+	case []byte:
+		return normalizeNewlines(string(d)), nil
+	}
+
+	b, err := json.Marshal(data)
+	return string(b), err
+}
+
+func normalizeNewlines(s string) string {
+	return strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(s)
+}
+
+// singleLine strips newlines from fields that can't span lines
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,52 @@
+package router
+
+import "strings"
+
+// templateVar is a variable in a mux template like {id:[0-9]+}
+type templateVar struct {
+	name    string
+	pattern string
+}
+
+// parseTemplate returns the variables in a mux host, path or query
+// template, and the template with their patterns removed
+func parseTemplate(tpl string) (string, []templateVar) {
+	var out strings.Builder
+	var vars []templateVar
+
+	for {
+		start := strings.Index(tpl, "{")
+		if start < 0 {
+			out.WriteString(tpl)
+			break
+		}
+
+		// Patterns can have braces of their own, like {id:[0-9]{4}}
+		end, depth := start, 0
+		for ; end < len(tpl); end++ {
+			if tpl[end] == '{' {
+				depth++
+			} else if tpl[end] == '}' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if end == len(tpl) {
+			out.WriteString(tpl)
+			break
+		}
+
+		v := templateVar{name: tpl[start+1 : end]}
+		if i := strings.Index(v.name, ":"); i >= 0 {
+			v.name, v.pattern = v.name[:i], v.name[i+1:]
+		}
+
+		out.WriteString(tpl[:start] + "{" + v.name + "}")
+		vars = append(vars, v)
+		tpl = tpl[end+1:]
+	}
+
+	return out.String(), vars
+}
@@ -0,0 +1,141 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/gschier/hemlock/interfaces"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultUploadMemory is used when the app doesn't configure how much of a
+// multipart request to keep in memory
+const defaultUploadMemory = 8 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+type UploadedFile struct {
+	header      *multipart.FileHeader
+	contentType string
+}
+
+func newUploadedFile(header *multipart.FileHeader) *UploadedFile {
+	return &UploadedFile{header: header}
+}
+
+func (f *UploadedFile) Filename() string {
+	return filepath.Base(f.header.Filename)
+}
+
+func (f *UploadedFile) Size() int64 {
+	return f.header.Size
+}
+
+func (f *UploadedFile) ContentType() string {
+	if f.contentType != "" {
+		return f.contentType
+	}
+
+	file, err := f.header.Open()
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer file.Close()
+
+	// DetectContentType never looks at more than 512 bytes
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "application/octet-stream"
+	}
+
+	f.contentType = http.DetectContentType(buf[:n])
+	return f.contentType
+}
+
+func (f *UploadedFile) Open() (io.ReadCloser, error) {
+	return f.header.Open()
+}
+
+func (f *UploadedFile) SaveTo(path string) error {
+	src, err := f.header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func (f *UploadedFile) Store(disk interfaces.Disk) (string, error) {
+	src, err := f.header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	b := make([]byte, 20)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	name := hex.EncodeToString(b) + strings.ToLower(filepath.Ext(f.Filename()))
+	err = disk.Put(name, src)
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// limitedBody is like http.MaxBytesReader but remembers whether the limit
+// was exceeded so the caller can respond with a 413
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func newLimitedBody(body io.ReadCloser, limit int64) *limitedBody {
+	return &limitedBody{ReadCloser: body, remaining: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+
+	// Read one byte past the limit so we can tell if there is more
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+
+	n = int(b.remaining)
+	b.remaining = 0
+	b.exceeded = true
+	return n, errBodyTooLarge
+}
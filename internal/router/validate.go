@@ -0,0 +1,55 @@
+package router
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/validation"
+	"net/http"
+)
+
+// defaultValidator is used when the app doesn't register its own
+var defaultValidator = validation.New()
+
+func validatorFor(app *hemlock.Application) *validation.Validator {
+	if app.Has(new(validation.Validator)) {
+		return app.Make(new(validation.Validator)).(*validation.Validator)
+	}
+	return defaultValidator
+}
+
+type invalidResponse struct {
+	Message string                      `json:"message"`
+	Errors  validation.ValidationErrors `json:"errors"`
+}
+
+// invalid responds to a request that failed validation. API requests get
+// the errors as JSON and form submissions are redirected back with the
+// errors and input flashed to the session.
+func invalid(req *Request, res *Response, errs validation.ValidationErrors) {
+	var sess interfaces.Session
+	if res.app.Has(&sess) {
+		res.app.Resolve(&sess)
+	}
+
+	if req.WantsJSON() || sess == nil {
+		setResult(req.R, res.JSON(http.StatusUnprocessableEntity, &invalidResponse{
+			Message: "The given data was invalid.",
+			Errors:  errs,
+		}))
+		return
+	}
+
+	var input map[string][]string
+	if req.R.MultipartForm != nil {
+		input = req.R.MultipartForm.Value
+	} else {
+		input = req.R.PostForm
+	}
+	validation.Flash(sess, errs, input)
+
+	back := req.Header("Referer")
+	if back == "" {
+		back = "/"
+	}
+	setResult(req.R, res.Redirect(back, http.StatusSeeOther))
+}
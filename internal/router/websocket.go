@@ -0,0 +1,55 @@
+package router
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/exceptions"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/websocket"
+	"net/http"
+)
+
+func (router *Router) WebSocket(uri string, callback interfaces.Callback, options ...websocket.Options) interfaces.Route {
+	return router.newRoute().WebSocket(uri, callback, options...)
+}
+
+func (r *Route) WebSocket(uri string, callback interfaces.Callback, options ...websocket.Options) interfaces.Route {
+	var opts websocket.Options
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	return r.Get(uri, func(req interfaces.Request, res interfaces.Response, app *hemlock.Application) interfaces.Result {
+		request := req.(*Request)
+		response := res.(*Response)
+
+		conn, err := websocket.Upgrade(response.W, request.R, opts)
+		if e, ok := err.(*websocket.HandshakeError); ok {
+			return res.Error(hemlock.NewHTTPError(e.Status, ""))
+		} else if err != nil {
+			return res.Error(err)
+		}
+
+		// Nothing can be written once the connection is taken over, so
+		// errors are only reported
+		defer func() {
+			if v := recover(); v != nil {
+				err = exceptions.NewPanicError(v)
+			}
+
+			if err != nil {
+				response.newResult().(*Result).exceptionHandler().Report(req, err)
+				_ = conn.Close(websocket.CloseInternalError, "")
+			} else {
+				_ = conn.Close(websocket.CloseNormal, "")
+			}
+		}()
+
+		app.Instance(conn)
+		results, err := app.ResolveIntoWith(callback, r.argResolver(request, app))
+		if err == nil && len(results) > 0 {
+			err, _ = results[len(results)-1].(error)
+		}
+
+		return response.Status(http.StatusSwitchingProtocols).(*Response).newResult()
+	})
+}
@@ -0,0 +1,103 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type contextKey string
+
+const sessionContextKey = contextKey("session")
+
+type Session struct {
+	id     string
+	values map[string]string
+
+	// flashed holds values flashed during the previous request and next
+	// holds values flashed during this one
+	flashed map[string]string
+	next    map[string]string
+
+	// loaded is whether the session came from the request's cookie
+	loaded bool
+}
+
+func newSession() *Session {
+	return &Session{
+		id:      newID(),
+		values:  make(map[string]string),
+		flashed: make(map[string]string),
+		next:    make(map[string]string),
+	}
+}
+
+// FromContext returns the session attached to ctx by the session
+// middleware, or nil if there isn't one
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionContextKey).(*Session)
+	return s
+}
+
+// IsNew returns whether the session was started by this request rather
+// than loaded from its cookie
+func (s *Session) IsNew() bool {
+	return !s.loaded
+}
+
+func (s *Session) ID() string {
+	return s.id
+}
+
+func (s *Session) Get(key string) string {
+	if v, ok := s.next[key]; ok {
+		return v
+	}
+
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+
+	return s.flashed[key]
+}
+
+func (s *Session) Has(key string) bool {
+	_, inValues := s.values[key]
+	_, inFlashed := s.flashed[key]
+	_, inNext := s.next[key]
+	return inValues || inFlashed || inNext
+}
+
+func (s *Session) Put(key, value string) {
+	s.values[key] = value
+}
+
+func (s *Session) Forget(key string) {
+	delete(s.values, key)
+	delete(s.flashed, key)
+	delete(s.next, key)
+}
+
+func (s *Session) Flash(key, value string) {
+	s.next[key] = value
+}
+
+func (s *Session) Regenerate() {
+	s.id = newID()
+}
+
+func (s *Session) Invalidate() {
+	s.values = make(map[string]string)
+	s.flashed = make(map[string]string)
+	s.next = make(map[string]string)
+	s.Regenerate()
+}
+
+func newID() string {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic("Failed to generate session ID: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
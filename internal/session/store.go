@@ -0,0 +1,176 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/internal/router"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultCookie   = "hemlock_session"
+	defaultLifetime = 2 * time.Hour
+)
+
+// Store keeps sessions in an encrypted cookie on the client
+type Store struct {
+	aead     cipher.AEAD
+	cookie   string
+	domain   string
+	lifetime time.Duration
+	secure   bool
+}
+
+type payload struct {
+	ID      string            `json:"id"`
+	Values  map[string]string `json:"v"`
+	Flashed map[string]string `json:"f"`
+	Expires int64             `json:"e"`
+}
+
+func NewStore(key string, config *hemlock.SessionConfig) (*Store, error) {
+	if key == "" {
+		return nil, errors.New("sessions require an app key")
+	}
+
+	// Derive a separate key so the app key isn't used directly
+	derived := sha256.Sum256([]byte("hemlock.session:" + key))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{aead: aead, cookie: defaultCookie, lifetime: defaultLifetime}
+	if config != nil {
+		if config.Cookie != "" {
+			s.cookie = config.Cookie
+		}
+		if config.Lifetime != 0 {
+			s.lifetime = config.Lifetime
+		}
+		s.domain = config.Domain
+		s.secure = config.Secure
+	}
+
+	return s, nil
+}
+
+// Middleware loads the session from the request cookie, makes it available
+// to the rest of the request and writes it back before the response is sent
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess := s.load(r)
+
+		hw := router.OnBeforeWrite(w, func() {
+			s.save(w, sess)
+		})
+
+		ctx := context.WithValue(r.Context(), sessionContextKey, sess)
+		ctx = router.WithInstance(ctx, sess)
+		next.ServeHTTP(hw, r.WithContext(ctx))
+		hw.Done()
+	})
+}
+
+func (s *Store) load(r *http.Request) *Session {
+	c, err := r.Cookie(s.cookie)
+	if err != nil {
+		return newSession()
+	}
+
+	p, err := s.decode(c.Value)
+	if err != nil || time.Now().Unix() > p.Expires {
+		return newSession()
+	}
+
+	sess := newSession()
+	sess.id = p.ID
+	sess.loaded = true
+	if p.Values != nil {
+		sess.values = p.Values
+	}
+	if p.Flashed != nil {
+		sess.flashed = p.Flashed
+	}
+
+	return sess
+}
+
+func (s *Store) save(w http.ResponseWriter, sess *Session) {
+	expires := time.Now().Add(s.lifetime)
+	value, err := s.encode(&payload{
+		ID:      sess.id,
+		Values:  sess.values,
+		Flashed: sess.next,
+		Expires: expires.Unix(),
+	})
+	if err != nil {
+		// Better to drop the session than fail the whole response
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookie,
+		Value:    value,
+		Path:     "/",
+		Domain:   s.domain,
+		Expires:  expires,
+		Secure:   s.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *Store) encode(p *payload) (string, error) {
+	plaintext, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, plaintext, []byte(s.cookie))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Store) decode(value string) (*payload, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("session cookie too short")
+	}
+
+	plaintext, err := s.aead.Open(nil, sealed[:n], sealed[n:], []byte(s.cookie))
+	if err != nil {
+		return nil, err
+	}
+
+	var p payload
+	err = json.Unmarshal(plaintext, &p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
@@ -8,14 +8,14 @@ import (
 	"strings"
 )
 
-func asset(app *hemlock.Application) interface{} {
+func asset(app func() *hemlock.Application) interface{} {
 	return func(name string) template.URL {
 		var (
 			err     error
 			fullURL *url2.URL
 		)
 
-		publicPrefix := app.Config.PublicPrefix
+		publicPrefix := app().Config.PublicPrefix
 
 		prefixAbsolute := strings.HasPrefix(publicPrefix, "https://") ||
 			strings.HasPrefix(publicPrefix, "http://") ||
@@ -27,7 +27,7 @@ func asset(app *hemlock.Application) interface{} {
 				panic(err)
 			}
 		} else {
-			fullURL, err = url2.Parse(app.Config.URL)
+			fullURL, err = url2.Parse(app().Config.URL)
 			if err != nil {
 				panic(err)
 			}
@@ -36,7 +36,7 @@ func asset(app *hemlock.Application) interface{} {
 
 		fullURL.Path = path.Join(fullURL.Path, name)
 
-		if app.IsProd() {
+		if app().IsProd() {
 			q := fullURL.Query()
 			q.Set("v", hemlock.CacheBustKey)
 			fullURL.RawQuery = q.Encode()
@@ -0,0 +1,13 @@
+package funcs
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/gate"
+)
+
+func can(app func() *hemlock.Application) interface{} {
+	return func(ability string, args ...interface{}) bool {
+		g := app().Make(new(gate.UserGate)).(*gate.UserGate)
+		return g.Allows(ability, args...)
+	}
+}
@@ -0,0 +1,27 @@
+package funcs
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/csrf"
+	"html/template"
+)
+
+func csrfToken(app func() *hemlock.Application) interface{} {
+	return func() string {
+		var sess interfaces.Session
+		app().Resolve(&sess)
+		return csrf.Token(sess)
+	}
+}
+
+func csrfField(app func() *hemlock.Application) interface{} {
+	return func() template.HTML {
+		var sess interfaces.Session
+		app().Resolve(&sess)
+		return template.HTML(
+			`<input type="hidden" name="` + csrf.FieldName + `" value="` +
+				template.HTMLEscapeString(csrf.Token(sess)) + `">`,
+		)
+	}
+}
@@ -2,14 +2,25 @@ package funcs
 
 import (
 	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/internal/templates"
 	"html/template"
 )
 
-func Funcs(app *hemlock.Application) *template.FuncMap {
-	return &template.FuncMap{
-		"asset":   asset(app),
-		"url":     url(app),
-		"partial": partial(app),
-		"route":   route(app),
+// Funcs returns the built-in template funcs. They're bound to templates once
+// and call app for the request being rendered.
+func Funcs() *templates.Funcs {
+	var fn templates.Funcs = func(app func() *hemlock.Application) template.FuncMap {
+		return template.FuncMap{
+			"asset":      asset(app),
+			"url":        url(app),
+			"partial":    partial(app),
+			"route":      route(app),
+			"can":        can(app),
+			"csrf_token": csrfToken(app),
+			"csrf_field": csrfField(app),
+			"errors":     errors(app),
+			"old":        old(app),
+		}
 	}
+	return &fn
 }
@@ -6,16 +6,16 @@ import (
 	"html/template"
 )
 
-func partial(app *hemlock.Application) interface{} {
+func partial(app func() *hemlock.Application) interface{} {
 	return func(name string, data ...interface{}) template.HTML {
 		var renderer templates.Renderer
-		app.Resolve(&renderer)
+		app().Resolve(&renderer)
 
 		var renderData interface{}
 		if len(data) > 0 {
 			renderData = data[0]
 		}
 
-		return template.HTML(renderer.RenderPartial(name, renderData))
+		return template.HTML(renderer.RenderPartial(name, renderData, app()))
 	}
 }
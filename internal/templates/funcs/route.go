@@ -1,25 +1,32 @@
 package funcs
 
 import (
+	"fmt"
 	"github.com/gschier/hemlock"
 	"github.com/gschier/hemlock/interfaces"
 	"html/template"
 	"strings"
 )
 
-func route(app *hemlock.Application) interface{} {
-	return func(name string, params ...string) template.URL {
+// route builds a URL from a route name and name=value params, like
+// {{ route "users.show" "user=1" "tab=posts" }}. Params the route doesn't
+// use are added to the query string.
+func route(app func() *hemlock.Application) interface{} {
+	return func(name string, params ...string) (template.URL, error) {
 		var router interfaces.Router
-		app.Resolve(&router)
+		app().Resolve(&router)
 
 		// Split name=value pairs into map
-		paramsMap := make(map[string]string)
+		paramsMap := make(interfaces.RouteParams)
 		for _, p := range params {
 			v := strings.SplitN(p, "=", 2)
-			// TODO: Better error messages here
+			if len(v) != 2 {
+				return "", fmt.Errorf("route param for '%s' must be name=value, got '%s'", name, p)
+			}
 			paramsMap[v[0]] = v[1]
 		}
 
-		return template.URL(router.Route(name, paramsMap))
+		u, err := router.RouteURL(name, paramsMap)
+		return template.URL(u), err
 	}
 }
@@ -6,10 +6,10 @@ import (
 	"html/template"
 )
 
-func url(app *hemlock.Application) interface{} {
+func url(app func() *hemlock.Application) interface{} {
 	return func(path string) template.URL {
 		var router interfaces.Router
-		app.Resolve(&router)
+		app().Resolve(&router)
 		return template.URL(router.URL(path))
 	}
 }
@@ -0,0 +1,29 @@
+package funcs
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/validation"
+)
+
+func errors(app func() *hemlock.Application) interface{} {
+	return func() validation.ValidationErrors {
+		return validation.FlashedErrors(flashSession(app()))
+	}
+}
+
+func old(app func() *hemlock.Application) interface{} {
+	return func(name string) string {
+		return validation.OldInput(flashSession(app()), name)
+	}
+}
+
+// flashSession returns the request's session, or nil if sessions aren't
+// enabled
+func flashSession(app *hemlock.Application) interfaces.Session {
+	var sess interfaces.Session
+	if app.Has(&sess) {
+		app.Resolve(&sess)
+	}
+	return sess
+}
@@ -4,23 +4,45 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"github.com/gschier/hemlock"
 	"html/template"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
+// Funcs builds template funcs. They call app when run to get the app of the
+// request being rendered, so they can use request-scoped services like the
+// current user.
+type Funcs func(app func() *hemlock.Application) template.FuncMap
+
 type Renderer struct {
 	root     string
+	app      *hemlock.Application
+	newFuncs Funcs
 	funcs    template.FuncMap
 	views    map[string]map[string]*template.Template
 	partials map[string]*template.Template
+
+	// bound holds copies of each template with funcs bound to them, so
+	// they're only bound once rather than on every render
+	bound map[*template.Template]*sync.Pool
+}
+
+// boundTemplate is a copy of a template whose funcs read app, which is set
+// to the request's app while it's rendered
+type boundTemplate struct {
+	t   *template.Template
+	app *hemlock.Application
 }
 
-func NewRenderer(root string, funcs template.FuncMap) *Renderer {
-	return &Renderer{root: root, funcs: funcs}
+func NewRenderer(root string, app *hemlock.Application, funcs Funcs) *Renderer {
+	r := &Renderer{root: root, app: app, newFuncs: funcs}
+	r.funcs = funcs(func() *hemlock.Application { return app })
+	return r
 }
 
 func (r *Renderer) Init() error {
@@ -36,6 +58,7 @@ func (r *Renderer) Init() error {
 
 	// Create all possible combinations of views to bases
 	r.views = map[string]map[string]*template.Template{}
+	r.bound = make(map[*template.Template]*sync.Pool)
 	for _, templatePath := range templatePaths {
 		viewName := strings.TrimPrefix(templatePath, filepath.Join(r.root, "views")+"/")
 		r.views[viewName] = map[string]*template.Template{}
@@ -63,6 +86,7 @@ func (r *Renderer) Init() error {
 			}
 
 			r.views[viewName][layoutName] = t
+			r.bound[t] = new(sync.Pool)
 		}
 	}
 
@@ -84,6 +108,7 @@ func (r *Renderer) Init() error {
 			return err
 		}
 		r.partials[name] = t
+		r.bound[t] = new(sync.Pool)
 	}
 
 	fmt.Printf(
@@ -104,14 +129,15 @@ func (r *Renderer) RenderString(w io.Writer, html string, data interface{}) erro
 	return t.Execute(w, data)
 }
 
-func (r *Renderer) RenderPartial(name string, data interface{}) string {
+// RenderPartial renders a partial for the request's app
+func (r *Renderer) RenderPartial(name string, data interface{}, app *hemlock.Application) string {
 	t, ok := r.partials[name]
 	if !ok {
 		panic("Partial not found with name " + name)
 	}
 
 	var w bytes.Buffer
-	err := t.Execute(&w, data)
+	err := r.execute(&w, t, name, data, app)
 	if err != nil {
 		panic("Failed to render partial: " + err.Error())
 	}
@@ -119,7 +145,8 @@ func (r *Renderer) RenderPartial(name string, data interface{}) string {
 	return w.String()
 }
 
-func (r *Renderer) RenderTemplate(w io.Writer, template, layout string, data interface{}) error {
+// RenderTemplate renders a view inside a layout for the request's app
+func (r *Renderer) RenderTemplate(w io.Writer, template, layout string, data interface{}, app *hemlock.Application) error {
 	if len(r.views) == 0 {
 		return errors.New(fmt.Sprintf("No views found in %s", r.root))
 	}
@@ -139,12 +166,18 @@ func (r *Renderer) RenderTemplate(w io.Writer, template, layout string, data int
 	}
 
 	if layout == "" {
-		return t.ExecuteTemplate(w, template, data)
+		return r.execute(w, t, template, data, app)
 	} else {
-		return t.ExecuteTemplate(w, layout, data)
+		return r.execute(w, t, layout, data, app)
 	}
 }
 
+// HasView returns whether a view exists that can be rendered in the layout
+func (r *Renderer) HasView(name, layout string) bool {
+	_, ok := r.views[name][layout]
+	return ok
+}
+
 func (r *Renderer) findTemplates(dirs ...string) ([]string, error) {
 	dir := filepath.Join(dirs...)
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
@@ -173,3 +206,30 @@ func (r *Renderer) findTemplates(dirs ...string) ([]string, error) {
 
 	return paths, nil
 }
+
+// execute renders a bound copy of t for the app. Copies are only made when
+// every existing one is in use. Parsed templates are never executed
+// directly so they can always be cloned.
+func (r *Renderer) execute(w io.Writer, t *template.Template, name string, data interface{}, app *hemlock.Application) error {
+	pool := r.bound[t]
+	b, _ := pool.Get().(*boundTemplate)
+	if b == nil {
+		c, err := t.Clone()
+		if err != nil {
+			return err
+		}
+		b = &boundTemplate{t: c}
+		c.Funcs(r.newFuncs(func() *hemlock.Application { return b.app }))
+	}
+
+	if app == nil {
+		app = r.app
+	}
+	b.app = app
+	defer func() {
+		b.app = nil
+		pool.Put(b)
+	}()
+
+	return b.t.ExecuteTemplate(w, name, data)
+}
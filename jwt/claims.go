@@ -0,0 +1,93 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gschier/hemlock/interfaces"
+	"time"
+)
+
+type contextKey string
+
+const claimsContextKey = contextKey("claims")
+
+// Claims is the payload of a token
+type Claims map[string]interface{}
+
+// FromRequest returns the claims verified by the Middleware, or nil
+func FromRequest(req interfaces.Request) Claims {
+	return FromContext(req.Context())
+}
+
+// FromContext returns the claims verified by the Middleware, or nil
+func FromContext(ctx context.Context) Claims {
+	c, _ := ctx.Value(claimsContextKey).(Claims)
+	return c
+}
+
+// Subject returns the 'sub' claim
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Issuer returns the 'iss' claim
+func (c Claims) Issuer() string {
+	return c.String("iss")
+}
+
+// Audience returns the 'aud' claim, which may be a string or a list
+func (c Claims) Audience() []string {
+	switch v := c["aud"].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		aud := make([]string, 0, len(v))
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				aud = append(aud, s)
+			}
+		}
+		return aud
+	default:
+		return nil
+	}
+}
+
+// ExpiresAt returns the 'exp' claim
+func (c Claims) ExpiresAt() (time.Time, bool, error) {
+	return c.Time("exp")
+}
+
+// String returns a claim as a string, or an empty string if it isn't one
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Time returns a NumericDate claim and whether it's set. It's an error for
+// the claim to be set to anything but a number.
+func (c Claims) Time(name string) (time.Time, bool, error) {
+	var seconds int64
+	switch v := c[name].(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("claim %s is not a number", name)
+		}
+		seconds = int64(f)
+	case float64:
+		seconds = int64(v)
+	case int64:
+		seconds = v
+	case int:
+		seconds = int64(v)
+	default:
+		return time.Time{}, false, fmt.Errorf("claim %s is not a number", name)
+	}
+	return time.Unix(seconds, 0), true, nil
+}
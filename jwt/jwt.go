@@ -0,0 +1,270 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/router"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTTL    = time.Hour
+	defaultLeeway = time.Minute
+)
+
+var (
+	ErrMalformed        = errors.New("token is malformed")
+	ErrUnknownKey       = errors.New("token signed with unknown key")
+	ErrInvalidSignature = errors.New("token signature is invalid")
+	ErrExpired          = errors.New("token has expired")
+	ErrNotYetValid      = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token issuer is invalid")
+	ErrInvalidAudience  = errors.New("token audience is invalid")
+)
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Service issues and verifies JSON Web Tokens
+type Service struct {
+	Issuer   string
+	Audience []string
+	TTL      time.Duration
+	Leeway   time.Duration
+
+	keys []*Key
+	now  func() time.Time
+}
+
+// New creates a Service that signs with the first key able to sign and
+// verifies with any of the keys
+func New(keys ...*Key) *Service {
+	return &Service{
+		TTL:    defaultTTL,
+		Leeway: defaultLeeway,
+		keys:   keys,
+		now:    time.Now,
+	}
+}
+
+// Issue signs a token containing the claims. The 'iat' and 'exp' claims,
+// as well as 'iss' and 'aud' if configured, are added if not present.
+func (s *Service) Issue(claims Claims) (string, error) {
+	key := s.signingKey()
+	if key == nil {
+		return "", errors.New("no key available for signing")
+	}
+
+	now := s.now()
+	payload := Claims{"iat": now.Unix()}
+	if s.TTL > 0 {
+		payload["exp"] = now.Add(s.TTL).Unix()
+	}
+	if s.Issuer != "" {
+		payload["iss"] = s.Issuer
+	}
+	if len(s.Audience) == 1 {
+		payload["aud"] = s.Audience[0]
+	} else if len(s.Audience) > 1 {
+		payload["aud"] = s.Audience
+	}
+	for k, v := range claims {
+		payload[k] = v
+	}
+
+	h, err := json.Marshal(&header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	input := encode(h) + "." + encode(p)
+	sig, err := key.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+
+	return input + "." + encode(sig), nil
+}
+
+// Verify checks the token's signature and registered claims and returns
+// its claims
+func (s *Service) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	key := s.verificationKey(&h)
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	if !key.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidSignature
+	}
+
+	var claims Claims
+	if err := decodeJSON(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+
+	err = s.validate(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// Middleware rejects requests without a valid bearer token with a JSON 401
+// response. Verified claims are added to the request context and can be
+// injected into route callbacks.
+//
+// For example:
+//
+//	api := r.With(tokens.Middleware)
+//	api.Get("/me", func(c jwt.Claims, res interfaces.Response) interfaces.Result { ... })
+func (s *Service) Middleware(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+	h := req.Header("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return unauthorized(res, "missing bearer token")
+	}
+
+	claims, err := s.Verify(strings.TrimSpace(strings.TrimPrefix(h, "Bearer ")))
+	if err != nil {
+		return unauthorized(res, err.Error())
+	}
+
+	ctx := context.WithValue(req.Context(), claimsContextKey, claims)
+	ctx = router.WithInstance(ctx, claims)
+	return next(req.WithContext(ctx), res)
+}
+
+// SetClock overrides the time used to issue and verify tokens
+func (s *Service) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+func (s *Service) validate(claims Claims) error {
+	now := s.now()
+
+	// A malformed time would otherwise be treated as missing, so a bad
+	// 'exp' would never expire
+	exp, hasExp, err := claims.Time("exp")
+	if err != nil {
+		return ErrMalformed
+	}
+	nbf, hasNbf, err := claims.Time("nbf")
+	if err != nil {
+		return ErrMalformed
+	}
+	iat, hasIat, err := claims.Time("iat")
+	if err != nil {
+		return ErrMalformed
+	}
+
+	if hasExp && now.After(exp.Add(s.Leeway)) {
+		return ErrExpired
+	}
+
+	if hasNbf && now.Add(s.Leeway).Before(nbf) {
+		return ErrNotYetValid
+	}
+
+	if hasIat && now.Add(s.Leeway).Before(iat) {
+		return ErrNotYetValid
+	}
+
+	if s.Issuer != "" && claims.Issuer() != s.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if len(s.Audience) > 0 && !intersects(claims.Audience(), s.Audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+func (s *Service) signingKey() *Key {
+	for _, k := range s.keys {
+		if k.CanSign() {
+			return k
+		}
+	}
+	return nil
+}
+
+// verificationKey finds the key named by the header. The algorithm must
+// match the key's so a token can't pick a weaker algorithm than intended.
+func (s *Service) verificationKey(h *header) *Key {
+	for _, k := range s.keys {
+		if h.KeyID != "" && k.ID != h.KeyID {
+			continue
+		}
+		if k.Algorithm == h.Algorithm {
+			return k
+		}
+	}
+	return nil
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func unauthorized(res interfaces.Response, message string) interfaces.Result {
+	return res.
+		Header("WWW-Authenticate", `Bearer error="invalid_token"`).
+		JSON(http.StatusUnauthorized, &errorBody{Error: message})
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJSON(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
@@ -0,0 +1,164 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// Key signs and verifies tokens with a single algorithm
+type Key struct {
+	ID        string
+	Algorithm string
+
+	secret     []byte
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+}
+
+// MinHMACSecretSize is the shortest secret accepted for HS256 keys, matching
+// the size of the hash
+const MinHMACSecretSize = 32
+
+// NewHMACKey creates an HS256 key from a shared secret of at least
+// MinHMACSecretSize bytes
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < MinHMACSecretSize {
+		return nil, fmt.Errorf("key %s: HS256 secrets must be at least %d bytes", id, MinHMACSecretSize)
+	}
+	return &Key{ID: id, Algorithm: HS256, secret: secret}, nil
+}
+
+// NewRSAKey creates an RS256 key. The private key may be nil if the key is
+// only used for verification.
+func NewRSAKey(id string, private *rsa.PrivateKey, public *rsa.PublicKey) *Key {
+	k := &Key{ID: id, Algorithm: RS256, publicKey: public}
+	if private != nil {
+		k.privateKey = private
+		k.publicKey = &private.PublicKey
+	}
+	return k
+}
+
+// NewEdDSAKey creates an Ed25519 key. The private key may be nil if the key
+// is only used for verification.
+func NewEdDSAKey(id string, private ed25519.PrivateKey, public ed25519.PublicKey) *Key {
+	k := &Key{ID: id, Algorithm: EdDSA, publicKey: public}
+	if private != nil {
+		k.privateKey = private
+		k.publicKey = private.Public()
+	}
+	return k
+}
+
+// ParseKey creates a key from PEM encoded PKCS #8 private and/or PKIX
+// public keys. Either may be nil.
+func ParseKey(id, algorithm string, privatePEM, publicPEM []byte) (*Key, error) {
+	var private, public interface{}
+	var err error
+
+	if privatePEM != nil {
+		private, err = parsePEM(privatePEM, x509.ParsePKCS8PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if publicPEM != nil {
+		public, err = parsePEM(publicPEM, x509.ParsePKIXPublicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch algorithm {
+	case RS256:
+		priv, _ := private.(*rsa.PrivateKey)
+		pub, _ := public.(*rsa.PublicKey)
+		if priv == nil && pub == nil {
+			return nil, fmt.Errorf("key %s is not an RSA key", id)
+		}
+		return NewRSAKey(id, priv, pub), nil
+	case EdDSA:
+		priv, _ := private.(ed25519.PrivateKey)
+		pub, _ := public.(ed25519.PublicKey)
+		if priv == nil && pub == nil {
+			return nil, fmt.Errorf("key %s is not an Ed25519 key", id)
+		}
+		return NewEdDSAKey(id, priv, pub), nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", algorithm)
+	}
+}
+
+// CanSign returns whether the key has the private material to sign tokens
+func (k *Key) CanSign() bool {
+	return k.secret != nil || k.privateKey != nil
+}
+
+func (k *Key) sign(input []byte) ([]byte, error) {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case RS256:
+		if k.privateKey == nil {
+			return nil, errors.New("key has no private key")
+		}
+		digest := sha256.Sum256(input)
+		return k.privateKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case EdDSA:
+		if k.privateKey == nil {
+			return nil, errors.New("key has no private key")
+		}
+		return k.privateKey.Sign(rand.Reader, input, crypto.Hash(0))
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", k.Algorithm)
+	}
+}
+
+func (k *Key) verify(input, signature []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case RS256:
+		pub, ok := k.publicKey.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		digest := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	case EdDSA:
+		pub, ok := k.publicKey.(ed25519.PublicKey)
+		if !ok {
+			return false
+		}
+		return ed25519.Verify(pub, input, signature)
+	default:
+		return false
+	}
+}
+
+func parsePEM(data []byte, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	return parse(block.Bytes)
+}
@@ -0,0 +1,117 @@
+package hemlock_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/jwt"
+	"github.com/gschier/hemlock/providers"
+	routeproviders "github.com/gschier/hemlock/support/providers"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testHMACSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestJWT_IssueAndVerify(t *testing.T) {
+	_, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	issuer := jwt.New(jwt.NewEdDSAKey("ed", edPrivate, nil))
+	issuer.Issuer = "hemlock"
+	issuer.Audience = []string{"mobile"}
+
+	hmacKey, err := jwt.NewHMACKey("hs", testHMACSecret)
+	assert.NoError(t, err)
+
+	_, err = jwt.NewHMACKey("short", []byte("secret"))
+	assert.EqualError(t, err, "key short: HS256 secrets must be at least 32 bytes", "Should reject short secrets")
+
+	verifier := jwt.New(
+		hmacKey,
+		jwt.NewRSAKey("rs", nil, &rsaPrivate.PublicKey),
+		jwt.NewEdDSAKey("ed", nil, edPrivate.Public().(ed25519.PublicKey)),
+	)
+	verifier.Issuer = "hemlock"
+	verifier.Audience = []string{"web", "mobile"}
+
+	token, err := issuer.Issue(jwt.Claims{"sub": "42"})
+	assert.NoError(t, err)
+
+	claims, err := verifier.Verify(token)
+	assert.NoError(t, err, "Should verify with matching kid")
+	assert.Equal(t, "42", claims.Subject())
+
+	rsaToken, err := jwt.New(jwt.NewRSAKey("rs", rsaPrivate, nil)).Issue(jwt.Claims{"iss": "hemlock", "aud": "web"})
+	assert.NoError(t, err)
+	_, err = verifier.Verify(rsaToken)
+	assert.NoError(t, err, "Should verify RS256")
+
+	_, err = verifier.Verify(token[:len(token)-4] + "AAAA")
+	assert.Equal(t, jwt.ErrInvalidSignature, err)
+
+	other := jwt.New(jwt.NewEdDSAKey("ed", edPrivate, nil))
+	other.Issuer = "someone-else"
+	wrongIssuer, _ := other.Issue(jwt.Claims{"aud": "web"})
+	_, err = verifier.Verify(wrongIssuer)
+	assert.Equal(t, jwt.ErrInvalidIssuer, err)
+
+	// Tokens expire after TTL plus leeway
+	verifier.SetClock(func() time.Time { return time.Now().Add(time.Hour + 30*time.Second) })
+	_, err = verifier.Verify(token)
+	assert.NoError(t, err, "Should allow clock skew")
+
+	verifier.SetClock(func() time.Time { return time.Now().Add(2 * time.Hour) })
+	_, err = verifier.Verify(token)
+	assert.Equal(t, jwt.ErrExpired, err)
+
+	// A non-numeric exp must not be treated as no expiry
+	neverExpires, _ := issuer.Issue(jwt.Claims{"aud": "web", "exp": "never"})
+	_, err = verifier.Verify(neverExpires)
+	assert.Equal(t, jwt.ErrMalformed, err)
+}
+
+func TestJWT_Middleware(t *testing.T) {
+	key, _ := jwt.NewHMACKey("", testHMACSecret)
+	tokens := jwt.New(key)
+
+	r := newTestRouter()
+	r.With(tokens.Middleware).Get("/me", func(c jwt.Claims, res interfaces.Response) interfaces.Result {
+		return res.Data(c.Subject())
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error": "missing bearer token"}`, w.Body.String())
+
+	token, _ := tokens.Issue(jwt.Claims{"sub": "42"})
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "42", w.Body.String(), "Should inject claims")
+}
+
+func TestJWTProvider_Keys(t *testing.T) {
+	assert.PanicsWithValue(t, "Failed to boot JWTProvider: key hs: HS256 secrets must be at least 32 bytes\n", func() {
+		hemlock.NewApplication(&hemlock.Config{
+			PublicPrefix: "/static",
+			JWT: &hemlock.JWTConfig{
+				Keys: []hemlock.JWTKey{{ID: "hs", Algorithm: jwt.HS256}},
+			},
+		}, []hemlock.Provider{
+			new(providers.TemplateFuncsProvider),
+			new(providers.TemplatesProvider),
+			new(routeproviders.RouteProvider),
+			new(providers.JWTProvider),
+		})
+	}, "Should fail to boot with an empty secret")
+}
@@ -0,0 +1,181 @@
+package negotiate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// JSON encodes any data as JSON
+type JSON struct{}
+
+func (e *JSON) MediaType() string {
+	return "application/json"
+}
+
+func (e *JSON) CanEncode(data interface{}) bool {
+	return true
+}
+
+func (e *JSON) Encode(w io.Writer, data interface{}) error {
+	return json.NewEncoder(w).Encode(data)
+}
+
+// XML encodes structs and slices as XML. Slices and arrays are wrapped in
+// an <items> element so the document has a single root.
+type XML struct{}
+
+func (e *XML) MediaType() string {
+	return "application/xml"
+}
+
+func (e *XML) CanEncode(data interface{}) bool {
+	if data == nil {
+		return false
+	}
+
+	switch indirect(reflect.TypeOf(data)).Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func (e *XML) Encode(w io.Writer, data interface{}) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return enc.Encode(data)
+	}
+
+	root := xml.StartElement{Name: xml.Name{Local: "items"}}
+	if err := enc.EncodeToken(root); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := enc.Encode(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// CSV encodes slices of structs as CSV with a header row. Columns are
+// named by `csv` tags, then `json` tags, then field names.
+type CSV struct{}
+
+func (e *CSV) MediaType() string {
+	return "text/csv"
+}
+
+func (e *CSV) CanEncode(data interface{}) bool {
+	if data == nil {
+		return false
+	}
+
+	t := indirect(reflect.TypeOf(data))
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) &&
+		indirect(t.Elem()).Kind() == reflect.Struct
+}
+
+func (e *CSV) Encode(w io.Writer, data interface{}) error {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	t := indirect(v.Type().Elem())
+
+	var fields []int
+	var header []string
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := columnName(t.Field(i))
+		if ok {
+			fields = append(fields, i)
+			header = append(header, name)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		for item.Kind() == reflect.Ptr {
+			item = item.Elem()
+		}
+
+		row := make([]string, len(fields))
+		if item.IsValid() {
+			for j, f := range fields {
+				row[j] = fmt.Sprint(item.Field(f).Interface())
+			}
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Text encodes any data with fmt
+type Text struct{}
+
+func (e *Text) MediaType() string {
+	return "text/plain"
+}
+
+func (e *Text) CanEncode(data interface{}) bool {
+	return true
+}
+
+func (e *Text) Encode(w io.Writer, data interface{}) error {
+	_, err := fmt.Fprint(w, data)
+	return err
+}
+
+func columnName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+
+	for _, tag := range []string{"csv", "json"} {
+		if value, ok := f.Tag.Lookup(tag); ok {
+			name := strings.Split(value, ",")[0]
+			if name == "-" {
+				return "", false
+			} else if name != "" {
+				return name, true
+			}
+		}
+	}
+
+	return f.Name, true
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
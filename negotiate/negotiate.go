@@ -0,0 +1,142 @@
+package negotiate
+
+import (
+	"github.com/gschier/hemlock/interfaces"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoders holds the encoders responses can be negotiated between, in
+// order of preference
+type Encoders struct {
+	encoders []interfaces.Encoder
+	mutex    sync.RWMutex
+}
+
+// NewEncoders returns Encoders holding the given encoders
+func NewEncoders(encoders ...interfaces.Encoder) *Encoders {
+	e := &Encoders{}
+	for _, enc := range encoders {
+		e.Register(enc)
+	}
+	return e
+}
+
+// Default returns Encoders for JSON, XML, CSV and plain text
+func Default() *Encoders {
+	return NewEncoders(&JSON{}, &XML{}, &CSV{}, &Text{})
+}
+
+// Register adds an encoder, replacing any existing one for the same media
+// type
+//
+// For example:
+//
+//	encoders := app.Make(new(negotiate.Encoders)).(*negotiate.Encoders)
+//	encoders.Register(&MessagePack{})
+func (e *Encoders) Register(enc interfaces.Encoder) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for i, existing := range e.encoders {
+		if existing.MediaType() == enc.MediaType() {
+			e.encoders[i] = enc
+			return
+		}
+	}
+
+	e.encoders = append(e.encoders, enc)
+}
+
+// For returns the encoders that can encode data, in order of preference
+func (e *Encoders) For(data interface{}) []interfaces.Encoder {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	var encoders []interfaces.Encoder
+	for _, enc := range e.encoders {
+		if enc.CanEncode(data) {
+			encoders = append(encoders, enc)
+		}
+	}
+
+	return encoders
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// Match returns the offered media type the Accept header prefers, or an
+// empty string if it accepts none of them. Ties go to the earliest offer
+// and an empty header accepts anything.
+func Match(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(accept)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := quality(ranges, offer)
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// quality returns the q value of the most specific range matching the
+// media type
+func quality(ranges []acceptRange, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.mediaType == mediaType:
+			s = 2
+		case strings.HasSuffix(r.mediaType, "/*") &&
+			strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*")):
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+
+	return q
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	return ranges
+}
@@ -0,0 +1,124 @@
+package hemlock_test
+
+import (
+	"fmt"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/negotiate"
+	"github.com/gschier/hemlock/providers"
+	routeproviders "github.com/gschier/hemlock/support/providers"
+	. "github.com/gschier/hemlock/testutil"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testCar struct {
+	Make  string `json:"make"`
+	Year  int    `json:"year" csv:"model_year"`
+	Notes string `json:"-"`
+}
+
+type testYAMLEncoder struct{}
+
+func (e *testYAMLEncoder) MediaType() string               { return "application/yaml" }
+func (e *testYAMLEncoder) CanEncode(data interface{}) bool { return true }
+func (e *testYAMLEncoder) Encode(w io.Writer, data interface{}) error {
+	_, err := fmt.Fprintf(w, "cars: %d\n", len(data.([]testCar)))
+	return err
+}
+
+type testYAMLProvider struct{}
+
+func (p *testYAMLProvider) Register(c interfaces.Container) {}
+
+func (p *testYAMLProvider) Boot(app *hemlock.Application) error {
+	encoders := app.Make(new(negotiate.Encoders)).(*negotiate.Encoders)
+	encoders.Register(&testYAMLEncoder{})
+	return nil
+}
+
+func TestNegotiate(t *testing.T) {
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+		new(providers.NegotiationProvider),
+		new(testYAMLProvider),
+	)
+
+	var r interfaces.Router
+	app.Resolve(&r)
+
+	cars := []testCar{{Make: "Volvo", Year: 1999}, {Make: "Saab", Year: 2004}}
+	r.Get("/cars", func(res interfaces.Response) interfaces.Result {
+		return res.Negotiate(cars)
+	})
+	r.Get("/name", func(res interfaces.Response) interfaces.Result {
+		return res.Negotiate("Volvo")
+	})
+	r.Post("/cars", func(res interfaces.Response) interfaces.Result {
+		return res.Status(http.StatusCreated).Negotiate(cars[0])
+	})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/cars", "")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"), "Should default to JSON")
+	assert.JSONEq(t, `[{"make":"Volvo","year":1999},{"make":"Saab","year":2004}]`, w.Body.String())
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	w = get("/cars", "text/html, text/csv;q=0.9, */*;q=0.1")
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "make,model_year\nVolvo,1999\nSaab,2004\n", w.Body.String())
+
+	w = get("/cars", "application/xml")
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		"<items>"+
+		"<testCar><Make>Volvo</Make><Year>1999</Year><Notes></Notes></testCar>"+
+		"<testCar><Make>Saab</Make><Year>2004</Year><Notes></Notes></testCar>"+
+		"</items>",
+		w.Body.String(),
+		"Should wrap slices in a root element",
+	)
+
+	w = get("/cars", "application/yaml")
+	assert.Equal(t, "cars: 2\n", w.Body.String(), "Should use registered encoder")
+
+	w = get("/name", "text/*")
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Volvo", w.Body.String())
+
+	w = get("/name", "text/csv")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code, "Should not CSV encode non-slices")
+
+	w = get("/cars", "image/png")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/cars", nil)
+	req.Header.Set("Accept", "image/png")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code, "Should replace a status set earlier")
+	assert.Equal(t, "Not Acceptable", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/cars", nil)
+	req.Header.Set("Accept", "application/xml")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t,
+		`<?xml version="1.0" encoding="UTF-8"?>`+"\n"+"<testCar><Make>Volvo</Make><Year>1999</Year><Notes></Notes></testCar>",
+		w.Body.String(),
+		"Should encode structs as the root element",
+	)
+}
@@ -0,0 +1,115 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete", "head", "options", "trace", "connect"}
+
+type docsOperation struct {
+	Method    string
+	Path      string
+	Operation *Operation
+}
+
+// RenderDocs writes an HTML page for browsing the document
+func (d *Document) RenderDocs(w io.Writer) error {
+	paths := make([]string, 0, len(d.Paths))
+	for p := range d.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	ops := make([]docsOperation, 0)
+	for _, p := range paths {
+		for _, m := range methodOrder {
+			if op, ok := (*d.Paths[p])[m]; ok {
+				ops = append(ops, docsOperation{Method: m, Path: p, Operation: op})
+			}
+		}
+	}
+
+	return docsTemplate.Execute(w, map[string]interface{}{
+		"Info":       d.Info,
+		"Operations": ops,
+		"Schemas":    d.Components.Schemas,
+	})
+}
+
+var docsFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"json": func(v interface{}) string {
+		b, _ := json.MarshalIndent(v, "", "  ")
+		return string(b)
+	},
+}
+
+var docsTemplate = template.Must(template.New("docs").Funcs(docsFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Info.Title }}</title>
+<style>
+body { margin: 0; font: 14px/1.5 -apple-system, BlinkMacSystemFont, sans-serif; color: #222; background: #f4f4f5; }
+header { padding: 24px 32px; background: #14532d; color: #fff; }
+header h1 { margin: 0 0 4px; font-size: 20px; }
+header p { margin: 0; opacity: 0.85; }
+section { margin: 24px 32px; background: #fff; border-radius: 6px; padding: 16px 24px; }
+h2 { font-size: 15px; margin: 0 0 12px; }
+h3 { font-size: 13px; margin: 12px 0 4px; color: #666; }
+details { border-top: 1px solid #eee; padding: 6px 0; }
+summary { cursor: pointer; font-family: monospace; }
+summary small { color: #666; font-family: sans-serif; }
+.method { display: inline-block; width: 64px; font-weight: bold; }
+.get { color: #1d4ed8; } .post { color: #15803d; } .put, .patch { color: #b45309; } .delete { color: #b91c1c; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: 4px 8px; vertical-align: top; border-top: 1px solid #eee; font-family: monospace; }
+td:first-child { width: 25%; }
+pre { margin: 4px 0; background: #18181b; color: #e4e4e7; padding: 8px 12px; overflow-x: auto; }
+</style>
+</head>
+<body>
+<header>
+	<h1>{{ .Info.Title }}</h1>
+	<p>Version {{ .Info.Version }}{{ if .Info.Description }} &middot; {{ .Info.Description }}{{ end }}</p>
+</header>
+
+<section>
+	<h2>Operations</h2>
+	{{ range .Operations }}
+	<details>
+		<summary><span class="method {{ .Method }}">{{ upper .Method }}</span>{{ .Path }} <small>{{ .Operation.Summary }}{{ if .Operation.OperationID }} ({{ .Operation.OperationID }}){{ end }}</small></summary>
+		{{ with .Operation.Parameters }}
+		<h3>Parameters</h3>
+		<table>{{ range . }}<tr><td>{{ .Name }}{{ if .Required }} *{{ end }}</td><td>{{ .In }}</td><td>{{ json .Schema }}</td></tr>{{ end }}</table>
+		{{ end }}
+		{{ with .Operation.RequestBody }}
+		<h3>Request body</h3>
+		{{ range $type, $media := .Content }}<pre>{{ $type }}
+{{ json $media.Schema }}</pre>{{ end }}
+		{{ end }}
+		<h3>Responses</h3>
+		{{ range $status, $resp := .Operation.Responses }}<pre>{{ $status }} {{ $resp.Description }}{{ range $type, $media := $resp.Content }}
+{{ json $media.Schema }}{{ end }}</pre>{{ end }}
+	</details>
+	{{ end }}
+</section>
+
+{{ if .Schemas }}
+<section>
+	<h2>Schemas</h2>
+	{{ range $name, $schema := .Schemas }}
+	<details>
+		<summary>{{ $name }}</summary>
+		<pre>{{ json $schema }}</pre>
+	</details>
+	{{ end }}
+</section>
+{{ end }}
+</body>
+</html>
+`))
@@ -0,0 +1,310 @@
+// Package openapi describes an API with an OpenAPI 3.1 document
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI version documents are written for
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+
+	// schemaNames holds the component name each type was added under
+	schemaNames map[reflect.Type]string
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// PathItem maps lowercase HTTP methods to their operations
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is the subset of JSON Schema used to describe Go types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// New returns an empty document
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      make(map[string]*PathItem),
+		Components: &Components{Schemas: make(map[string]*Schema)},
+
+		schemaNames: make(map[reflect.Type]string),
+	}
+}
+
+// Operation adds an operation to the document, replacing any with the same
+// path and method
+func (d *Document) Operation(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+// Schema describes t. Named structs are added to the document's components
+// and referenced so they're only described once.
+func (d *Document) Schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.Schema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return d.structSchema(t, "json")
+		}
+		return d.ref(t)
+	}
+
+	// Interfaces and anything else could be any value
+	return &Schema{}
+}
+
+// StructSchema describes the fields of a struct that have the tag, like
+// "query" or "form"
+func (d *Document) StructSchema(t reflect.Type, tag string) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return d.structSchema(t, tag)
+}
+
+func (d *Document) ref(t reflect.Type) *Schema {
+	name, ok := d.schemaNames[t]
+	if !ok {
+		name = d.schemaName(t)
+		d.schemaNames[t] = name
+
+		// Add a placeholder first so recursive types terminate
+		d.Components.Schemas[name] = &Schema{}
+		*d.Components.Schemas[name] = *d.structSchema(t, "json")
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// schemaName picks a component name for t. Types sharing a name with one
+// from another package are prefixed with their package, like models.User.
+func (d *Document) schemaName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := d.Components.Schemas[name]; !taken {
+		return name
+	}
+
+	name = path.Base(t.PkgPath()) + "." + t.Name()
+	for i := 2; ; i++ {
+		if _, taken := d.Components.Schemas[name]; !taken {
+			return name
+		}
+		name = path.Base(t.PkgPath()) + "." + t.Name() + strconv.Itoa(i)
+	}
+}
+
+func (d *Document) structSchema(t reflect.Type, tag string) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.addFields(s, t, tag)
+	return s
+}
+
+// addFields adds the struct's fields to the schema. Untagged embedded
+// structs have their fields promoted like encoding/json does, with fields
+// closer to the top taking precedence.
+func (d *Document) addFields(s *Schema, t reflect.Type, tag string) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && tag == "json" {
+			if name, _ := f.Tag.Lookup(tag); name == "" {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, ok := FieldName(f, tag)
+		if !ok {
+			continue
+		}
+
+		fs := d.Schema(f.Type)
+		if ApplyRules(fs, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+
+	for _, et := range embedded {
+		promoted := &Schema{Properties: make(map[string]*Schema)}
+		d.addFields(promoted, et, tag)
+		for _, name := range promoted.Required {
+			if _, ok := s.Properties[name]; !ok {
+				s.Required = append(s.Required, name)
+			}
+		}
+		for name, fs := range promoted.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = fs
+			}
+		}
+	}
+}
+
+// FieldName returns the name a struct field has for the tag. JSON fields
+// without a tag use the Go name like encoding/json does.
+func FieldName(f reflect.StructField, tag string) (string, bool) {
+	value, ok := f.Tag.Lookup(tag)
+	if value == "-" || (!ok && tag != "json") {
+		return "", false
+	}
+
+	name := strings.Split(value, ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+// ApplyRules adds the constraints from a `validate` tag to the schema and
+// returns whether the field is required
+func ApplyRules(s *Schema, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "uuid":
+			s.Format = "uuid"
+		case "in":
+			s.Enum = strings.Fields(param)
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			applySize(s, name, n)
+		}
+	}
+	return required
+}
+
+func applySize(s *Schema, rule string, n float64) {
+	if s.Type == "string" {
+		i := int(n)
+		if rule != "max" {
+			s.MinLength = &i
+		}
+		if rule != "min" {
+			s.MaxLength = &i
+		}
+		return
+	}
+
+	if s.Type == "integer" || s.Type == "number" {
+		if rule != "max" {
+			s.Minimum = &n
+		}
+		if rule != "min" {
+			s.Maximum = &n
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package hemlock_test
+
+import (
+	"encoding/json"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/openapi"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type apiPet struct {
+	ID   int      `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type createPetInput struct {
+	Owner int    `route:"owner"`
+	Name  string `json:"name" validate:"required,max=20"`
+	Kind  string `json:"kind" validate:"in=cat dog"`
+}
+
+type listPetsInput struct {
+	Limit int    `query:"limit" validate:"max=100"`
+	Sort  string `query:"sort"`
+}
+
+func TestRouter_OpenAPI(t *testing.T) {
+	r := newTestRouter()
+	r.Get("/pets", func(in listPetsInput, res interfaces.Response) interfaces.Result {
+		return res.JSON(http.StatusOK, []apiPet{})
+	}).Name("pets.index").Summary("List pets").Returns(http.StatusOK, []apiPet{})
+	r.Post("/owners/{owner:[0-9]+}/pets", func(in createPetInput, res interfaces.Response) interfaces.Result {
+		return res.JSON(http.StatusCreated, apiPet{})
+	}).Name("pets.store").Returns(http.StatusCreated, apiPet{})
+	r.Any("/anything", func(res interfaces.Response) interfaces.Result {
+		return res.Data("ok")
+	})
+	r.ServeOpenAPI("/openapi.json", openapi.Info{Title: "Pets", Version: "1.0.0"})
+	r.ServeAPIDocs("/docs", openapi.Info{Title: "Pets", Version: "1.0.0"})
+
+	doc := r.OpenAPI(openapi.Info{Title: "Pets", Version: "1.0.0"})
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Len(t, doc.Paths, 2, "Should skip the spec route, static files and any-method routes")
+
+	list := (*doc.Paths["/pets"])["get"]
+	assert.Equal(t, "pets.index", list.OperationID)
+	assert.Equal(t, "List pets", list.Summary)
+	assert.Len(t, list.Parameters, 2)
+	assert.Equal(t, "limit", list.Parameters[0].Name)
+	assert.Equal(t, "query", list.Parameters[0].In)
+	assert.Equal(t, "integer", list.Parameters[0].Schema.Type)
+	assert.Equal(t, 100.0, *list.Parameters[0].Schema.Maximum)
+	assert.Equal(t, "array", list.Responses["200"].Content["application/json"].Schema.Type)
+	assert.Equal(t, "#/components/schemas/apiPet", list.Responses["200"].Content["application/json"].Schema.Items.Ref)
+	assert.Contains(t, doc.Components.Schemas["apiPet"].Properties, "tags")
+
+	store := (*doc.Paths["/owners/{owner}/pets"])["post"]
+	assert.Equal(t, "owner", store.Parameters[0].Name)
+	assert.Equal(t, "integer", store.Parameters[0].Schema.Type, "Should type params from bound fields")
+	assert.Equal(t, "^[0-9]+$", store.Parameters[0].Schema.Pattern)
+
+	body := store.RequestBody.Content["application/json"].Schema
+	assert.True(t, store.RequestBody.Required)
+	assert.Equal(t, []string{"name"}, body.Required)
+	assert.Equal(t, 20, *body.Properties["name"].MaxLength)
+	assert.Equal(t, []string{"cat", "dog"}, body.Properties["kind"].Enum)
+	assert.NotContains(t, body.Properties, "Owner", "Should leave route fields out of the body")
+	assert.NotNil(t, store.Responses["201"])
+	assert.NotNil(t, store.Responses["422"], "Should document validation errors")
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var served map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	assert.Equal(t, "Pets", served["info"].(map[string]interface{})["title"])
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "/owners/{owner}/pets")
+}
+
+// Info shares its name with openapi.Info
+type Info struct {
+	Name string `json:"name"`
+}
+
+type apiTimestamps struct {
+	CreatedAt time.Time `json:"created_at" validate:"required"`
+}
+
+type apiPost struct {
+	apiTimestamps
+	*Info
+	Name  string `json:"title"`
+	Draft bool   `json:"-"`
+}
+
+func TestOpenAPI_Schemas(t *testing.T) {
+	doc := openapi.New(openapi.Info{Title: "Test", Version: "1"})
+
+	assert.Equal(t, "#/components/schemas/Info", doc.Schema(reflect.TypeOf(Info{})).Ref)
+	assert.Equal(t, "#/components/schemas/openapi.Info", doc.Schema(reflect.TypeOf(openapi.Info{})).Ref, "Should not reuse schemas of other types")
+	assert.Equal(t, "#/components/schemas/Info", doc.Schema(reflect.TypeOf(&Info{})).Ref)
+	assert.Contains(t, doc.Components.Schemas["openapi.Info"].Properties, "title")
+
+	post := doc.Components.Schemas[strings.TrimPrefix(doc.Schema(reflect.TypeOf(apiPost{})).Ref, "#/components/schemas/")]
+	b, _ := json.Marshal(post)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"created_at": {"type": "string", "format": "date-time"},
+			"name": {"type": "string"},
+			"title": {"type": "string"}
+		},
+		"required": ["created_at"]
+	}`, string(b), "Should promote embedded fields")
+}
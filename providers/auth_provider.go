@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/auth"
+	"github.com/gschier/hemlock/interfaces"
+)
+
+// AuthProvider registers the auth Manager and the "auth" middleware alias.
+// The application must bind an auth.UserProvider for it to look up users
+// with.
+type AuthProvider struct{}
+
+func (p *AuthProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (*auth.Manager, error) {
+		var users auth.UserProvider
+		app.Resolve(&users)
+		return auth.NewManager(users), nil
+	})
+
+	c.Bind(func(app *hemlock.Application) (*auth.Auth, error) {
+		var req interfaces.Request
+		app.Resolve(&req)
+		return auth.FromRequest(app.Make(new(auth.Manager)).(*auth.Manager), req), nil
+	})
+}
+
+func (p *AuthProvider) Boot(app *hemlock.Application) error {
+	var router interfaces.Router
+	app.Resolve(&router)
+
+	router.UseG(app.Make(new(auth.Manager)).(*auth.Manager).Middleware)
+	router.Alias("auth", auth.Required())
+	return nil
+}
@@ -0,0 +1,55 @@
+package providers
+
+import (
+	"fmt"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/broadcast"
+	"github.com/gschier/hemlock/interfaces"
+	"strings"
+)
+
+// BroadcastProvider registers the Broadcaster and the endpoints clients
+// subscribe to channels through. Private and presence channels should be
+// authorized on it in other providers' Boot methods, which requires the
+// AuthProvider.
+type BroadcastProvider struct{}
+
+func (p *BroadcastProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (*broadcast.Broadcaster, error) {
+		config := app.Config.Broadcasting
+		if config == nil {
+			config = &hemlock.BroadcastConfig{}
+		}
+
+		var backend broadcast.Backend
+		switch strings.ToLower(config.Driver) {
+		case "", "memory":
+			backend = broadcast.NewMemoryBackend()
+		case "redis":
+			addr := config.RedisAddr
+			if addr == "" {
+				addr = "localhost:6379"
+			}
+			backend = broadcast.NewRedisBackend(addr, config.RedisPassword)
+		default:
+			return nil, fmt.Errorf("unknown broadcasting driver %s", config.Driver)
+		}
+
+		b := broadcast.New(backend)
+		b.Guards = config.Guards
+		return b, nil
+	})
+}
+
+func (p *BroadcastProvider) Boot(app *hemlock.Application) error {
+	var router interfaces.Router
+	app.Resolve(&router)
+
+	prefix := "/broadcasting"
+	if app.Config.Broadcasting != nil && app.Config.Broadcasting.Path != "" {
+		prefix = app.Config.Broadcasting.Path
+	}
+
+	app.Make(new(broadcast.Broadcaster)).(*broadcast.Broadcaster).Routes(router, prefix)
+	return nil
+}
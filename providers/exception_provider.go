@@ -0,0 +1,21 @@
+package providers
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/exceptions"
+	"github.com/gschier/hemlock/interfaces"
+)
+
+// ExceptionProvider registers the default ExceptionHandler. Apps can
+// register their own in a provider listed after this one to replace it.
+type ExceptionProvider struct{}
+
+func (p *ExceptionProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (interfaces.ExceptionHandler, error) {
+		return exceptions.New(app), nil
+	})
+}
+
+func (p *ExceptionProvider) Boot(app *hemlock.Application) error {
+	return nil
+}
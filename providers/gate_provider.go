@@ -0,0 +1,33 @@
+package providers
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/auth"
+	"github.com/gschier/hemlock/gate"
+	"github.com/gschier/hemlock/interfaces"
+)
+
+// GateProvider registers the authorization Gate. Abilities and policies
+// should be defined on it in other providers' Boot methods. It requires the
+// AuthProvider.
+type GateProvider struct{}
+
+func (p *GateProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (*gate.Gate, error) {
+		return gate.New(), nil
+	})
+
+	c.Bind(func(app *hemlock.Application) (*gate.UserGate, error) {
+		a := app.Make(new(auth.Auth)).(*auth.Auth)
+		g := app.Make(new(gate.Gate)).(*gate.Gate)
+		return g.ForUser(a.User()), nil
+	})
+}
+
+func (p *GateProvider) Boot(app *hemlock.Application) error {
+	var router interfaces.Router
+	app.Resolve(&router)
+
+	router.UseG(app.Make(new(gate.Gate)).(*gate.Gate).Middleware)
+	return nil
+}
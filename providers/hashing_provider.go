@@ -0,0 +1,19 @@
+package providers
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/hashing"
+)
+
+type HashingProvider struct{}
+
+func (p *HashingProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (interfaces.Hasher, error) {
+		return hashing.NewHasher(app.Config.Hashing)
+	})
+}
+
+func (p *HashingProvider) Boot(app *hemlock.Application) error {
+	return nil
+}
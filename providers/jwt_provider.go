@@ -0,0 +1,86 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"errors"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/jwt"
+)
+
+var errNoJWTKey = errors.New("JWTs require keys in the JWT config or the app Key to be set")
+
+// JWTProvider registers the JWT Service and the "jwt" middleware alias
+type JWTProvider struct{}
+
+func (p *JWTProvider) Register(c interfaces.Container) {
+	c.Singleton(newJWTService)
+}
+
+// Boot builds the Service itself so bad keys fail to boot with an error
+// rather than panicking when the Service is first resolved
+func (p *JWTProvider) Boot(app *hemlock.Application) error {
+	s, err := newJWTService(app)
+	if err != nil {
+		return err
+	}
+	app.Instance(s)
+
+	var router interfaces.Router
+	app.Resolve(&router)
+
+	router.Alias("jwt", s.Middleware)
+	return nil
+}
+
+func newJWTService(app *hemlock.Application) (*jwt.Service, error) {
+	config := app.Config.JWT
+	if config == nil {
+		config = &hemlock.JWTConfig{}
+	}
+
+	keys, err := jwtKeys(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fall back to a key derived from the app key
+	if len(keys) == 0 && app.Config.Key == "" {
+		return nil, errNoJWTKey
+	} else if len(keys) == 0 {
+		secret := sha256.Sum256([]byte("hemlock.jwt:" + app.Config.Key))
+		key, _ := jwt.NewHMACKey("", secret[:])
+		keys = append(keys, key)
+	}
+
+	s := jwt.New(keys...)
+	s.Issuer = config.Issuer
+	s.Audience = config.Audience
+	if config.TTL != 0 {
+		s.TTL = config.TTL
+	}
+	if config.Leeway != 0 {
+		s.Leeway = config.Leeway
+	}
+
+	return s, nil
+}
+
+// jwtKeys parses the keys in the config
+func jwtKeys(config *hemlock.JWTConfig) ([]*jwt.Key, error) {
+	keys := make([]*jwt.Key, 0, len(config.Keys))
+	for _, k := range config.Keys {
+		var key *jwt.Key
+		var err error
+		if k.Algorithm == jwt.HS256 {
+			key, err = jwt.NewHMACKey(k.ID, k.Secret)
+		} else {
+			key, err = jwt.ParseKey(k.ID, k.Algorithm, k.Private, k.Public)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,21 @@
+package providers
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/negotiate"
+)
+
+// NegotiationProvider registers the encoders used by Response.Negotiate.
+// Other providers can register more encoders on it in their Boot methods.
+type NegotiationProvider struct{}
+
+func (p *NegotiationProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (*negotiate.Encoders, error) {
+		return negotiate.Default(), nil
+	})
+}
+
+func (p *NegotiationProvider) Boot(app *hemlock.Application) error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package providers
+
+import (
+	"errors"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/internal/csrf"
+	internalrouter "github.com/gschier/hemlock/internal/router"
+	"github.com/gschier/hemlock/internal/session"
+)
+
+type SessionProvider struct{}
+
+func (p *SessionProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (*session.Store, error) {
+		return session.NewStore(app.Config.Key, app.Config.Sessions)
+	})
+}
+
+func (p *SessionProvider) Boot(app *hemlock.Application) error {
+	if app.Config.Key == "" {
+		return errors.New("sessions require the app Key to be set")
+	}
+
+	var router interfaces.Router
+	var store session.Store
+	app.Resolve(&router, &store)
+
+	router.UseG(store.Middleware)
+
+	if app.Config.Sessions == nil || !app.Config.Sessions.DisableCSRF {
+		rt, ok := router.(*internalrouter.Router)
+		if !ok {
+			return errors.New("CSRF protection requires the built-in router")
+		}
+		router.Use(csrf.Middleware(rt))
+	}
+
+	return nil
+}
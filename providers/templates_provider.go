@@ -4,7 +4,6 @@ import (
 	"github.com/gschier/hemlock"
 	"github.com/gschier/hemlock/interfaces"
 	"github.com/gschier/hemlock/internal/templates"
-	"html/template"
 )
 
 type TemplatesProvider struct{}
@@ -13,10 +12,10 @@ func (p *TemplatesProvider) Register(c interfaces.Container) {
 	c.Singleton(func(app *hemlock.Application) (*templates.Renderer, error) {
 		dir := app.Path(app.Config.TemplatesDirectory)
 
-		var fm template.FuncMap
-		app.Resolve(&fm)
+		var fns templates.Funcs
+		app.Resolve(&fns)
 
-		r := templates.NewRenderer(dir, fm)
+		r := templates.NewRenderer(dir, app, fns)
 
 		err := r.Init()
 		if err != nil {
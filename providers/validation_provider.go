@@ -0,0 +1,22 @@
+package providers
+
+import (
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/validation"
+)
+
+// ValidationProvider registers the Validator used for bound callback
+// arguments. Custom rules and translated messages should be added to it in
+// other providers' Boot methods.
+type ValidationProvider struct{}
+
+func (p *ValidationProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (*validation.Validator, error) {
+		return validation.New(), nil
+	})
+}
+
+func (p *ValidationProvider) Boot(app *hemlock.Application) error {
+	return nil
+}
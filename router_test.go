@@ -0,0 +1,902 @@
+package hemlock_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/exceptions"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/providers"
+	routeproviders "github.com/gschier/hemlock/support/providers"
+	. "github.com/gschier/hemlock/testutil"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRouter() interfaces.Router {
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	)
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	return r
+}
+
+func newUploadRequest(files map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, contents := range files {
+		fw, _ := mw.CreateFormFile("files", name)
+		fw.Write([]byte(contents))
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestRouter_Upload(t *testing.T) {
+	r := newTestRouter()
+	r.Post("/upload", func(req interfaces.Request, res interfaces.Response) interfaces.Result {
+		files := req.Files("files")
+		assert.Len(t, files, 2, "Should receive both files")
+		assert.Nil(t, req.File("missing"), "Should return nil for missing field")
+
+		f := req.File("files")
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		defer rc.Close()
+		contents, _ := ioutil.ReadAll(rc)
+
+		return res.Sprintf("%s %d %s", f.ContentType(), f.Size(), contents)
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, newUploadRequest(map[string]string{"a.txt": "hello", "b.txt": "world"}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "text/plain; charset=utf-8 5 ")
+}
+
+func TestRouter_UploadLimits(t *testing.T) {
+	r := newTestRouter()
+	cb := func(res interfaces.Response) interfaces.Result {
+		return res.Data("ok")
+	}
+	r.Post("/upload", cb).MaxFileSize(4)
+	r.Post("/body", cb).MaxBodySize(16)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, newUploadRequest(map[string]string{"a.txt": "hello"}))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "File over limit should be rejected")
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/body", bytes.NewBufferString("this body is much too long"))
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "Body over limit should be rejected")
+}
+
+func TestRouter_TypedParams(t *testing.T) {
+	r := newTestRouter()
+	r.Get("/posts/{post}/comments/{id}", func(res interfaces.Response, p struct {
+		Post string `route:"post"`
+		ID   int    `route:"id"`
+	}) interfaces.Result {
+		return res.Sprintf("%s %d", p.Post, p.ID)
+	})
+	r.Get("/users/{id}", func(req interfaces.Request, res interfaces.Response) interfaces.Result {
+		return res.Sprintf("%s %d", req.Param("id"), req.ParamInt("id"))
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/hello/comments/42", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello 42", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/hello/comments/abc", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "Should 404 on bad param")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/7", nil))
+	assert.Equal(t, "7 7", w.Body.String())
+}
+
+func TestRouter_PositionalParams(t *testing.T) {
+	r := newTestRouter()
+	r.Get("/posts/{post}/comments/{id}", func(res interfaces.Response, post, id string) interfaces.Result {
+		return res.Sprintf("%s %s", post, id)
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/hello/comments/42", nil))
+	assert.Equal(t, "hello 42", w.Body.String(), "Should pass params in URL order")
+}
+
+type testBindInput struct {
+	ID    int      `route:"id"`
+	Page  int      `query:"page"`
+	Name  string   `json:"name" form:"name"`
+	Tags  []string `json:"tags" form:"tag"`
+	Admin bool     `json:"admin"`
+}
+
+type testJSONInput struct {
+	hemlock.Input
+	Name string `json:"name" validate:"required"`
+}
+
+type testTaggedService struct {
+	Name string `json:"name" form:"name"`
+}
+
+func TestRouter_Bind(t *testing.T) {
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	)
+	app.Instance(&testTaggedService{Name: "service"})
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	r.Post("/users/{id}", func(res interfaces.Response, in *testBindInput) interfaces.Result {
+		return res.Sprintf("%d %d %s %v %v", in.ID, in.Page, in.Name, in.Tags, in.Admin)
+	})
+	r.Post("/manual", func(req interfaces.Request, res interfaces.Response) interfaces.Result {
+		var in testBindInput
+		if err := req.Bind(&in); err != nil {
+			return res.Status(err.(*hemlock.HTTPError).Status).Data(err.Error())
+		}
+		return res.Data(in.Name)
+	})
+	r.Post("/json", func(res interfaces.Response, in testJSONInput) interfaces.Result {
+		return res.Data(in.Name)
+	})
+	r.Post("/service", func(res interfaces.Response, s *testTaggedService) interfaces.Result {
+		return res.Data(s.Name)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/3?page=2", bytes.NewBufferString(`{"name":"Greg","tags":["a","b"],"admin":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "3 2 Greg [a b] true", w.Body.String(), "Should bind JSON")
+
+	req = httptest.NewRequest(http.MethodPost, "/users/3?page=2", bytes.NewBufferString(`{"id":99,"page":5,"name":"Greg"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "3 2 Greg [] false", w.Body.String(), "Should not let the body overwrite route and query values")
+
+	req = httptest.NewRequest(http.MethodPost, "/users/3", bytes.NewBufferString("name=Greg&tag=a&tag=b&admin=true"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "3 0 Greg [a b] true", w.Body.String(), "Should bind form")
+
+	req = httptest.NewRequest(http.MethodPost, "/users/3", bytes.NewBufferString(`{"name":`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Should reject bad JSON")
+
+	req = httptest.NewRequest(http.MethodPost, "/users/3", bytes.NewBufferString(`<name/>`))
+	req.Header.Set("Content-Type", "application/xml")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, "Should reject unknown content types")
+
+	req = httptest.NewRequest(http.MethodPost, "/manual", bytes.NewBufferString(`{"name":"Greg"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "Greg", w.Body.String(), "Should bind manually")
+
+	req = httptest.NewRequest(http.MethodPost, "/manual", bytes.NewBufferString(`<name/>`))
+	req.Header.Set("Content-Type", "application/xml")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, "Should return HTTPErrors when binding manually")
+
+	req = httptest.NewRequest(http.MethodPost, "/json", bytes.NewBufferString(`{"name":"Greg"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "Greg", w.Body.String(), "Should bind structs embedding Input")
+
+	req = httptest.NewRequest(http.MethodPost, "/json", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "Should validate structs embedding Input")
+
+	req = httptest.NewRequest(http.MethodPost, "/service", bytes.NewBufferString("name=spoofed"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "service", w.Body.String(), "Should resolve tagged structs from the container first")
+}
+
+func TestRouter_JSON(t *testing.T) {
+	app := hemlock.NewApplication(&hemlock.Config{
+		Env:          "production",
+		Key:          "secret",
+		PublicPrefix: "/static",
+		HTTP: &hemlock.HTTPConfig{
+			JSONEnvelope:  "data",
+			JSONPCallback: "callback",
+		},
+	}, []hemlock.Provider{
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	})
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	r.Get("/users", func(res interfaces.Response) interfaces.Result {
+		return res.JSON(http.StatusCreated, []string{"greg"})
+	})
+	r.Get("/error", func(res interfaces.Response) interfaces.Result {
+		return res.JSON(http.StatusBadRequest, map[string]string{"error": "bad"})
+	})
+	r.Get("/stream", func(res interfaces.Response) interfaces.Result {
+		i := 0
+		return res.JSONStream(http.StatusOK, func() (interface{}, bool, error) {
+			i++
+			return i, i <= 3, nil
+		})
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"data\":[\"greg\"]}\n", w.Body.String(), "Should wrap in envelope")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/error", nil))
+	assert.Equal(t, "{\"error\":\"bad\"}\n", w.Body.String(), "Should not wrap errors")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?callback=cb", nil))
+	assert.Equal(t, "application/javascript; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `/**/ typeof cb === 'function' && cb({"data":["greg"]});`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.Equal(t, `{"data":[1,2,3]}`, w.Body.String())
+
+	dev := newTestRouter()
+	dev.Get("/dev", func(res interfaces.Response) interfaces.Result {
+		return res.Data(map[string]int{"a": 1})
+	})
+	w = httptest.NewRecorder()
+	dev.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dev", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "{\n  \"a\": 1\n}\n", w.Body.String(), "Should indent in development")
+}
+
+type testExceptionHandler struct {
+	*exceptions.Handler
+	reported []error
+}
+
+func (h *testExceptionHandler) Report(req interfaces.Request, err error) {
+	h.reported = append(h.reported, err)
+}
+
+type testExceptionProvider struct {
+	handler *testExceptionHandler
+}
+
+func (p *testExceptionProvider) Register(c interfaces.Container) {
+	c.Singleton(func(app *hemlock.Application) (interfaces.ExceptionHandler, error) {
+		p.handler = &testExceptionHandler{Handler: exceptions.New(app)}
+		return p.handler, nil
+	})
+}
+
+func (p *testExceptionProvider) Boot(app *hemlock.Application) error {
+	return nil
+}
+
+func TestRouter_DataReader(t *testing.T) {
+	r := newTestRouter()
+	r.Get("/reader", func(res interfaces.Response) interfaces.Result {
+		return res.Header("Content-Type", "text/plain").Data(bytes.NewReader([]byte("from a reader")))
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reader", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "from a reader", w.Body.String(), "Should copy readers instead of encoding them")
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+}
+
+func TestRouter_Static(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "public-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app.css"), []byte("body { color: red; }"), 0644))
+
+	app := hemlock.NewApplication(&hemlock.Config{
+		PublicPrefix:    "/static",
+		PublicDirectory: dir,
+	}, []hemlock.Provider{
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	})
+
+	var r interfaces.Router
+	app.Resolve(&r)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/app.css", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "body { color: red; }", w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/css")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/missing.css", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRouter_Errors(t *testing.T) {
+	exceptionProvider := new(testExceptionProvider)
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+		exceptionProvider,
+	)
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	r.Get("/missing", func(res interfaces.Response) interfaces.Result {
+		return res.Error(hemlock.NotFound().WithMessage("No such post"))
+	})
+	r.Get("/broken", func(res interfaces.Response) interfaces.Result {
+		return res.Error(errors.New("database is down"))
+	})
+	r.Get("/stream", func(res interfaces.Response) interfaces.Result {
+		i := 0
+		return res.JSONStream(http.StatusOK, func() (interface{}, bool, error) {
+			i++
+			if i > 2 {
+				return nil, false, errors.New("cursor closed")
+			}
+			return i, true, nil
+		})
+	})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/missing", "text/html")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "No such post", w.Body.String())
+
+	w = get("/missing", "application/json")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"message": "No such post"}`, w.Body.String())
+
+	w = get("/missing", "application/problem+json")
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "No such post",
+		"instance": "/missing"
+	}`, w.Body.String())
+
+	w = get("/broken", "application/json")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"message": "Internal Server Error"}`, w.Body.String(), "Should hide internal errors")
+
+	w = get("/stream", "application/json")
+	assert.Equal(t, "[1,2", w.Body.String(), "Should cut off failed streams")
+
+	handler := exceptionProvider.handler
+	assert.Len(t, handler.reported, 5, "Should report through the app's handler")
+	assert.Contains(t, handler.reported[3].Error(), "database is down")
+	assert.Contains(t, handler.reported[4].Error(), "cursor closed", "Should report stream errors")
+}
+
+func TestRouter_ProductionErrors(t *testing.T) {
+	app := hemlock.NewApplication(&hemlock.Config{
+		Env:          "production",
+		Key:          "secret",
+		PublicPrefix: "/static",
+	}, []hemlock.Provider{
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	})
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	r.Get("/broken", func(res interfaces.Response) interfaces.Result {
+		return res.Error(errors.New("database is down"))
+	})
+	r.Get("/wrapped", func(res interfaces.Response) interfaces.Result {
+		return res.Status(http.StatusBadRequest).Error(fmt.Errorf("loading post: %w", hemlock.NotFound()))
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/broken", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "Internal Server Error", w.Body.String(), "Should hide internal errors")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wrapped", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "Should keep the status of wrapped HTTPErrors")
+	assert.Equal(t, "Not Found", w.Body.String())
+}
+
+func newEnvTestRouter(env string) interfaces.Router {
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	)
+	app.Config.Env = env
+
+	var r interfaces.Router
+	app.Resolve(&r)
+	r.Get("/posts/{id}", func(res interfaces.Response) interfaces.Result {
+		var posts map[string]string
+		posts["oops"] = "assignment to nil map"
+		return res.Data("unreachable")
+	}).Name("posts.show")
+	return r
+}
+
+func TestRouter_DevErrorPage(t *testing.T) {
+	r := newEnvTestRouter("development")
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/42?draft=1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "assignment to entry in nil map", "Should show the panic")
+	assert.Contains(t, body, "posts.show", "Should show the route")
+	assert.Contains(t, body, `posts[&#34;oops&#34;] = &#34;assignment to nil map&#34;`, "Should show source")
+	assert.Contains(t, body, "<td>Authorization</td><td>[redacted]</td>", "Should hide credentials")
+	assert.Contains(t, body, "*templates.Renderer (singleton)", "Should list bindings")
+
+	req = httptest.NewRequest(http.MethodGet, "/posts/42", nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.JSONEq(t, `{"message": "Internal Server Error"}`, w.Body.String(), "Should not render page for APIs")
+
+	w = httptest.NewRecorder()
+	newEnvTestRouter("production").Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/42", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "Internal Server Error", w.Body.String(), "Should not show details in production")
+}
+
+func TestRouter_NotFoundAndMethodNotAllowed(t *testing.T) {
+	r := newTestRouter()
+	r.Use(func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+		res.Header("X-Middleware", "ran")
+		return next(req, res)
+	})
+	r.Post("/posts", func(res interfaces.Response) interfaces.Result {
+		return res.Data("created")
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	req.Header.Set("Accept", "application/json")
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.JSONEq(t, `{"message": "Method Not Allowed"}`, w.Body.String(), "Should use the ExceptionHandler")
+	assert.Equal(t, "ran", w.Header().Get("X-Middleware"), "Should run middleware")
+
+	r.Prefix("/admin").Group(func(g interfaces.Router) {
+		g.NotFound(func(req interfaces.Request, res interfaces.Response) interfaces.Result {
+			return res.Sprintf("Nothing at %s", req.Path())
+		})
+	})
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "Nothing at /missing", w.Body.String())
+	assert.Equal(t, "ran", w.Header().Get("X-Middleware"))
+}
+
+func TestRouter_MiddlewareResult(t *testing.T) {
+	r := newTestRouter()
+	r.Use(func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+		result := next(req, res)
+		result.Header().Set("X-Status", strconv.Itoa(result.StatusCode()))
+		if result.Err() != nil {
+			result.Header().Set("X-Error", "yes")
+		}
+		return result
+	})
+	r.Get("/teapot", func(res interfaces.Response) interfaces.Result {
+		return res.Data("short and stout")
+	})
+	r.Get("/missing", func(res interfaces.Response) interfaces.Result {
+		return res.Error(hemlock.NotFound())
+	})
+	r.With(func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+		return res.Status(http.StatusTeapot).Header("X-Before", "kept").Data("blocked")
+	}).Get("/blocked", func(res interfaces.Response) interfaces.Result {
+		t.Fatal("Should not call callback")
+		return nil
+	})
+
+	teapot := func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+		return next(req, res.Status(http.StatusTeapot).Header("X-Before", "kept"))
+	}
+	r.With(teapot).Get("/status", func(res interfaces.Response) interfaces.Result {
+		return res.Data("brewing")
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/teapot", nil))
+	assert.Equal(t, "200", w.Header().Get("X-Status"), "Should see downstream status after it's written")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, "404", w.Header().Get("X-Status"))
+	assert.Equal(t, "yes", w.Header().Get("X-Error"), "Should see downstream error")
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/blocked", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "418", w.Header().Get("X-Status"), "Should see short-circuited result")
+	assert.Equal(t, "blocked", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code, "Should keep status set before next")
+	assert.Equal(t, "kept", w.Header().Get("X-Before"))
+	assert.Equal(t, "brewing", w.Body.String())
+}
+
+func TestRouter_RouteMiddleware(t *testing.T) {
+	r := newTestRouter()
+
+	var order []string
+	track := func(name string) interfaces.Middleware {
+		return func(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+			order = append(order, name)
+			return next(req, res)
+		}
+	}
+
+	r.Alias("session", track("session"))
+	r.Alias("auth", track("auth"))
+	r.Alias("api", "throttle", "auth")
+	r.Alias("throttle", track("throttle"))
+	r.MiddlewarePriority("session", "auth")
+
+	cb := func(res interfaces.Response) interfaces.Result {
+		return res.Data("ok")
+	}
+	r.Get("/one", cb).Middleware(track("one"))
+	r.Get("/two", cb)
+	r.Get("/api", cb).Middleware("api", track("inline"), "session")
+	r.Prefix("/admin").Middleware("auth").Group(func(g interfaces.Router) {
+		g.Get("/users", cb)
+	})
+
+	get := func(path string) []string {
+		order = nil
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		return order
+	}
+
+	assert.Equal(t, []string{"one"}, get("/one"))
+	assert.Empty(t, get("/two"), "Should not leak to other routes")
+	assert.Equal(t, []string{"throttle", "session", "inline", "auth"}, get("/api"), "Should expand groups and sort by priority")
+	assert.Equal(t, []string{"auth"}, get("/admin/users"), "Should apply to groups")
+
+	// Changing an alias after the handler is built still takes effect
+	r.Alias("auth", track("auth2"))
+	assert.Equal(t, []string{"auth2"}, get("/admin/users"), "Should resolve aliases again")
+
+	r.Get("/missing", cb).Middleware("nope")
+	assert.PanicsWithValue(t, "Middleware alias nope is not registered", func() {
+		r.Handler()
+	}, "Should report unknown aliases when the handler is built")
+}
+
+type photoController struct{}
+
+func (photoController) Index(res interfaces.Response) interfaces.Result {
+	return res.Data("index")
+}
+
+func (photoController) Create(res interfaces.Response) interfaces.Result {
+	return res.Data("create")
+}
+
+func (photoController) Show(req interfaces.Request, res interfaces.Response) interfaces.Result {
+	return res.Data("show " + req.Param("photo"))
+}
+
+func (photoController) Update(req interfaces.Request, res interfaces.Response) interfaces.Result {
+	return res.Data("update " + req.Param("photo"))
+}
+
+func (photoController) Destroy(res interfaces.Response) interfaces.Result {
+	return res.Data("destroy")
+}
+
+type commentController struct{}
+
+func (commentController) Index(req interfaces.Request, res interfaces.Response) interfaces.Result {
+	return res.Data("comments of " + req.Param("post"))
+}
+
+func (commentController) Show(req interfaces.Request, res interfaces.Response) interfaces.Result {
+	return res.Data("comment " + req.Param("comment"))
+}
+
+func TestRouter_Resource(t *testing.T) {
+	r := newTestRouter()
+	r.Resource("photos", photoController{}, interfaces.ResourceOptions{Except: []string{"destroy"}})
+	r.APIResource("categories", photoController{}, interfaces.ResourceOptions{Only: []string{"index", "create"}})
+	r.Resource("posts.comments", commentController{}, interfaces.ResourceOptions{Shallow: true})
+
+	send := func(method, path string) (int, string) {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := send(http.MethodGet, "/photos")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "index", body)
+
+	_, body = send(http.MethodGet, "/photos/create")
+	assert.Equal(t, "create", body, "Should not match create as an ID")
+
+	_, body = send(http.MethodGet, "/photos/12")
+	assert.Equal(t, "show 12", body)
+
+	_, body = send(http.MethodPatch, "/photos/12")
+	assert.Equal(t, "update 12", body)
+
+	code, _ = send(http.MethodPost, "/photos")
+	assert.Equal(t, http.StatusMethodNotAllowed, code, "Should skip methods the controller doesn't have")
+
+	code, _ = send(http.MethodDelete, "/photos/12")
+	assert.Equal(t, http.StatusMethodNotAllowed, code, "Should skip excepted actions")
+
+	_, body = send(http.MethodGet, "/categories")
+	assert.Equal(t, "index", body)
+
+	code, _ = send(http.MethodGet, "/categories/create")
+	assert.Equal(t, http.StatusNotFound, code, "Should skip form routes for APIs")
+
+	_, body = send(http.MethodGet, "/posts/3/comments")
+	assert.Equal(t, "comments of 3", body)
+
+	_, body = send(http.MethodGet, "/comments/7")
+	assert.Equal(t, "comment 7", body, "Should not nest shallow member routes")
+
+	assert.Equal(t, r.URL("/photos/12"), r.Route("photos.show", interfaces.RouteParams{"photo": "12"}))
+	assert.Equal(t, r.URL("/categories"), r.Route("categories.index", nil))
+	assert.Equal(t, r.URL("/posts/3/comments"), r.Route("posts.comments.index", interfaces.RouteParams{"post": "3"}))
+	assert.Equal(t, r.URL("/comments/7"), r.Route("comments.show", interfaces.RouteParams{"comment": "7"}))
+
+	r.Resource("videos", videoController{})
+	_, body = send(http.MethodGet, "/videos/4")
+	assert.Equal(t, "video 4", body, "Should find pointer receiver methods on controllers passed by value")
+
+	assert.PanicsWithValue(t, "Resource tags: hemlock_test.videoController has none of the methods for its routes", func() {
+		r.Resource("tags", videoController{}, interfaces.ResourceOptions{Only: []string{"destroy"}})
+	}, "Should report controllers without any of the actions")
+}
+
+type videoController struct{}
+
+func (c *videoController) Show(req interfaces.Request, res interfaces.Response) interfaces.Result {
+	return res.Data("video " + req.Param("video"))
+}
+
+type testAuthor struct {
+	ID   string
+	Name string
+}
+
+func TestRouter_Model(t *testing.T) {
+	r := newTestRouter()
+
+	authors := map[string]*testAuthor{"1": {ID: "1", Name: "Ada"}, "2": {ID: "2", Name: "Grace"}}
+	r.Model("author", func(id string) (*testAuthor, error) {
+		if id == "broken" {
+			return nil, hemlock.Forbidden()
+		}
+		return authors[id], nil
+	})
+
+	r.Get("/authors/{author}", func(author *testAuthor, res interfaces.Response) interfaces.Result {
+		return res.Data("hello " + author.Name)
+	})
+	r.Get("/authors/{author}/follows/{other}", func(author, other *testAuthor, res interfaces.Response) interfaces.Result {
+		return res.Data(author.Name + " follows " + other.Name)
+	}).ModelParams("author", "other")
+	r.Get("/writers/{id}", func(author *testAuthor, res interfaces.Response) interfaces.Result {
+		return res.Data("hello " + author.Name)
+	}).ModelParams("id")
+
+	assert.PanicsWithValue(t, "Model lookup for *hemlock_test.testAuthor is already registered", func() {
+		r.Model("other", func(id string) *testAuthor { return nil })
+	}, "Should not replace lookups")
+
+	send := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", "application/json")
+		r.Handler().ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	code, body := send("/authors/1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "hello Ada", body)
+
+	_, body = send("/authors/1/follows/2")
+	assert.Equal(t, "Ada follows Grace", body, "Should look up each model param")
+
+	_, body = send("/writers/2")
+	assert.Equal(t, "hello Grace", body, "Should use the route's param")
+
+	code, _ = send("/authors/3")
+	assert.Equal(t, http.StatusNotFound, code, "Should 404 when lookup finds nothing")
+
+	code, _ = send("/authors/broken")
+	assert.Equal(t, http.StatusForbidden, code, "Should respond with lookup error")
+}
+
+func testRouteMiddleware(req interfaces.Request, res interfaces.Response, next interfaces.Next) interfaces.Result {
+	return next(req, res)
+}
+
+func TestRouter_Routes(t *testing.T) {
+	r := newTestRouter()
+	r.Get("/", func(res interfaces.Response) interfaces.Result {
+		return res.Data("home")
+	}).Name("home").Middleware("auth")
+	r.Resource("photos", photoController{}, interfaces.ResourceOptions{Only: []string{"show"}})
+	r.Host("api.example.com").Prefix("/v1").Middleware("throttle").Group(func(g interfaces.Router) {
+		g.Use(testRouteMiddleware)
+		g.Any("/ping", func(res interfaces.Response) interfaces.Result {
+			return res.Data("pong")
+		}).Middleware("auth")
+	})
+
+	routes := make(map[string]interfaces.RouteInfo)
+	for _, info := range r.Routes() {
+		routes[info.Path] = info
+	}
+
+	assert.Equal(t, "home", routes["/"].Name)
+	assert.Equal(t, []string{http.MethodGet}, routes["/"].Methods)
+	assert.Equal(t, []string{"github.com/gschier/hemlock/internal/router.logRequests", "auth"}, routes["/"].Middleware, "Should include global middleware")
+	assert.Contains(t, routes["/"].Handler, "hemlock_test.TestRouter_Routes")
+
+	assert.Equal(t, "photos.show", routes["/photos/{photo}"].Name)
+	assert.Equal(t, "hemlock_test.photoController.Show", routes["/photos/{photo}"].Handler)
+
+	assert.Equal(t, "api.example.com", routes["/v1/ping"].Host)
+	assert.Empty(t, routes["/v1/ping"].Methods, "Should match any method")
+	assert.Equal(t, []string{
+		"github.com/gschier/hemlock/internal/router.logRequests",
+		"throttle",
+		"github.com/gschier/hemlock_test.testRouteMiddleware",
+		"auth",
+	}, routes["/v1/ping"].Middleware, "Should include the group's middleware")
+
+	_, ok := routes["/v1"]
+	assert.False(t, ok, "Should skip groups")
+}
+
+func TestRouter_SignedRoute(t *testing.T) {
+	r := newTestRouter()
+	r.Get("/unsubscribe/{user}", func(req interfaces.Request, res interfaces.Response) interfaces.Result {
+		return res.Data("unsubscribed " + req.Param("user"))
+	}).Name("unsubscribe").Middleware("signed")
+
+	send := func(u string) (int, string) {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, u, nil))
+		return w.Code, w.Body.String()
+	}
+
+	signed := r.SignedRoute("unsubscribe", interfaces.RouteParams{"user": "42"}, time.Time{})
+	code, body := send(signed)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "unsubscribed 42", body)
+
+	code, _ = send(strings.Replace(signed, "/42", "/43", 1))
+	assert.Equal(t, http.StatusForbidden, code, "Should reject changed params")
+
+	code, _ = send(r.Route("unsubscribe", interfaces.RouteParams{"user": "42"}))
+	assert.Equal(t, http.StatusForbidden, code, "Should reject unsigned URLs")
+
+	temporary := r.SignedRoute("unsubscribe", interfaces.RouteParams{"user": "42"}, time.Now().Add(time.Hour))
+	code, _ = send(temporary)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = send(strings.Replace(temporary, "expires=", "expires=9", 1))
+	assert.Equal(t, http.StatusForbidden, code, "Should reject extended expiry")
+
+	expired := r.SignedRoute("unsubscribe", interfaces.RouteParams{"user": "42"}, time.Now().Add(-time.Minute))
+	code, _ = send(expired)
+	assert.Equal(t, http.StatusForbidden, code, "Should reject expired URLs")
+}
+
+func TestRouter_RouteURL(t *testing.T) {
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	)
+	app.Config.URL = "https://example.com/app"
+
+	var r interfaces.Router
+	app.Resolve(&r)
+
+	cb := func(res interfaces.Response) interfaces.Result {
+		return res.Data("ok")
+	}
+	r.Get("/users/{user:[0-9]+}", cb).Name("users.show")
+	r.Host("{team}.example.com").Get("/dashboard", cb).Name("dashboard")
+
+	u, err := r.RouteURL("users.show", interfaces.RouteParams{"user": "1", "tab": "posts", "page": "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/app/users/1?page=2&tab=posts", u, "Should put unused params in the query")
+
+	u, err = r.RouteURL("users.show", interfaces.RouteParams{"user": "1"}, interfaces.URLOptions{Relative: true, Fragment: "bio"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/app/users/1#bio", u)
+
+	u, err = r.RouteURL("dashboard", interfaces.RouteParams{"team": "acme"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://acme.example.com/dashboard", u, "Should build the route's host")
+
+	_, err = r.RouteURL("missing", nil)
+	assert.Error(t, err)
+
+	_, err = r.RouteURL("users.show", interfaces.RouteParams{"user": "abc"})
+	assert.Error(t, err, "Should check params match their patterns")
+
+	assert.Equal(t, "https://example.com/app/users/1?tab=posts", r.Route("users.show", interfaces.RouteParams{"user": "1", "tab": "posts"}))
+}
@@ -0,0 +1,142 @@
+package hemlock_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"github.com/gschier/hemlock"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/providers"
+	routeproviders "github.com/gschier/hemlock/support/providers"
+	. "github.com/gschier/hemlock/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads lines up to the blank line ending an event
+func readEvent(br *bufio.Reader) string {
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil || line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestRouter_Stream(t *testing.T) {
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	)
+	app.Config.HTTP = &hemlock.HTTPConfig{SSEHeartbeat: 20 * time.Millisecond}
+
+	var r interfaces.Router
+	app.Resolve(&r)
+
+	done := make(chan struct{})
+	r.Get("/events", func(res interfaces.Response) interfaces.Result {
+		return res.Stream(func(w interfaces.EventWriter) error {
+			defer close(done)
+
+			w.Retry(3 * time.Second)
+			w.Send(interfaces.Event{ID: "after-" + w.LastEventID(), Event: "greeting", Data: "hello\nworld"})
+			w.Send(interfaces.Event{ID: "2", Data: map[string]int{"count": 2}})
+
+			// Wait for heartbeats until the client goes away
+			<-w.Context().Done()
+			return w.Send(interfaces.Event{Data: "too late"})
+		})
+	})
+
+	events := make(chan interfaces.Event, 2)
+	events <- interfaces.Event{Data: "one"}
+	events <- interfaces.Event{Data: "two"}
+	close(events)
+	r.Get("/channel", func(res interfaces.Response) interfaces.Result {
+		return res.SSE(events)
+	})
+
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	br := bufio.NewReader(resp.Body)
+	assert.Equal(t, "retry: 3000\n", readEvent(br))
+	assert.Equal(t, "id: after-1\nevent: greeting\ndata: hello\ndata: world\n", readEvent(br), "Should resume after Last-Event-ID")
+	assert.Equal(t, "id: 2\ndata: {\"count\":2}\n", readEvent(br))
+	assert.Equal(t, ": heartbeat\n", readEvent(br), "Should send heartbeats while the stream is idle")
+
+	resp.Body.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stream should stop when the client goes away")
+	}
+
+	resp, err = http.Get(srv.URL + "/channel")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	br = bufio.NewReader(resp.Body)
+	assert.Equal(t, "data: one\n", readEvent(br))
+	assert.Equal(t, "data: two\n", readEvent(br))
+	assert.Equal(t, "", readEvent(br), "Should end when the channel closes")
+}
+
+func TestRouter_StreamCompressed(t *testing.T) {
+	app := NewTestApplication(
+		new(providers.TemplateFuncsProvider),
+		new(providers.TemplatesProvider),
+		new(routeproviders.RouteProvider),
+	)
+
+	// Responses are only compressed in production
+	app.Config.Env = "production"
+
+	var r interfaces.Router
+	app.Resolve(&r)
+
+	r.Get("/events", func(res interfaces.Response) interfaces.Result {
+		return res.Stream(func(w interfaces.EventWriter) error {
+			w.Send(interfaces.Event{Data: "first"})
+			<-w.Context().Done()
+			return nil
+		})
+	})
+
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	// This blocks until the gzip stream is flushed
+	gz, err := gzip.NewReader(resp.Body)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "data: first\n", readEvent(bufio.NewReader(gz)), "Should flush through compression")
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalDisk stores files in a directory on the local filesystem
+type LocalDisk struct {
+	root string
+}
+
+func NewLocalDisk(root string) *LocalDisk {
+	return &LocalDisk{root: root}
+}
+
+// Put writes the contents of r to name, relative to the disk's root
+func (d *LocalDisk) Put(name string, r io.Reader) error {
+	p := d.Path(name)
+	err := os.MkdirAll(filepath.Dir(p), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// Path returns the full path of name on the disk
+func (d *LocalDisk) Path(name string) string {
+	return filepath.Join(d.root, filepath.Clean("/"+name))
+}
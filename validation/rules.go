@@ -0,0 +1,162 @@
+package validation
+
+import (
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// builtinRules are registered on every new Validator. Note that numbers
+// and bools are never empty, so use a pointer field to require them.
+var builtinRules = map[string]Rule{
+	"required":  func(f Field) bool { return !isEmpty(f.Value) },
+	"email":     email,
+	"min":       func(f Field) bool { return compareSize(f, func(size, n float64) bool { return size >= n }) },
+	"max":       func(f Field) bool { return compareSize(f, func(size, n float64) bool { return size <= n }) },
+	"len":       func(f Field) bool { return compareSize(f, func(size, n float64) bool { return size == n }) },
+	"numeric":   numeric,
+	"alpha":     func(f Field) bool { return allRunes(f, unicode.IsLetter) },
+	"alpha_num": func(f Field) bool { return allRunes(f, isAlphaNum) },
+	"in":        in,
+	"url":       isURL,
+	"uuid":      func(f Field) bool { return uuidRegexp.MatchString(str(f.Value)) },
+	"confirmed": confirmed,
+}
+
+var defaultMessages = map[string]string{
+	"required":  "The :field field is required.",
+	"email":     "The :field must be a valid email address.",
+	"min":       "The :field must be at least :param.",
+	"max":       "The :field may not be greater than :param.",
+	"len":       "The :field must be :param.",
+	"numeric":   "The :field must be a number.",
+	"alpha":     "The :field may only contain letters.",
+	"alpha_num": "The :field may only contain letters and numbers.",
+	"in":        "The selected :field is invalid.",
+	"url":       "The :field format is invalid.",
+	"uuid":      "The :field must be a valid UUID.",
+	"confirmed": "The :field confirmation does not match.",
+}
+
+func email(f Field) bool {
+	s := str(f.Value)
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+func numeric(f Field) bool {
+	switch f.Value.Kind() {
+	case reflect.String:
+		_, err := strconv.ParseFloat(f.Value.String(), 64)
+		return err == nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// in checks the value is one of the space-separated options, like
+// `validate:"in=draft published"`
+func in(f Field) bool {
+	s := str(f.Value)
+	for _, option := range strings.Fields(f.Param) {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
+
+func isURL(f Field) bool {
+	u, err := url.ParseRequestURI(str(f.Value))
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// confirmed checks the value matches a sibling field named like
+// "password_confirmation" or PasswordConfirmation
+func confirmed(f Field) bool {
+	name := f.Name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+
+	t := f.Struct.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		n, _ := fieldName(sf)
+		if n != name+"_confirmation" && n != name+"Confirmation" {
+			continue
+		}
+
+		other := f.Struct.Field(i)
+		for other.Kind() == reflect.Ptr && !other.IsNil() {
+			other = other.Elem()
+		}
+
+		return other.IsValid() && reflect.DeepEqual(other.Interface(), f.Value.Interface())
+	}
+
+	return false
+}
+
+// compareSize compares the param to a string's length, a slice's length
+// or a number's value
+func compareSize(f Field, cmp func(size, n float64) bool) bool {
+	n, err := strconv.ParseFloat(f.Param, 64)
+	if err != nil {
+		panic("Validation rule for " + f.Name + " needs a numeric param, got " + f.Param)
+	}
+
+	var size float64
+	switch f.Value.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(f.Value.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size = float64(f.Value.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(f.Value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(f.Value.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = f.Value.Float()
+	default:
+		return false
+	}
+
+	return cmp(size, n)
+}
+
+func allRunes(f Field, fn func(r rune) bool) bool {
+	if f.Value.Kind() != reflect.String {
+		return false
+	}
+
+	for _, r := range f.Value.String() {
+		if !fn(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isAlphaNum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// str returns the value as a string, or an empty string if it isn't one
+func str(v reflect.Value) string {
+	if v.Kind() != reflect.String {
+		return ""
+	}
+	return v.String()
+}
@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"encoding/json"
+	"github.com/gschier/hemlock/interfaces"
+	"strings"
+)
+
+// Session keys that failed form submissions are flashed under
+const (
+	ErrorsKey   = "_errors"
+	OldInputKey = "_old_input"
+)
+
+// Flash stores errors and the submitted input in the session for the next
+// request. Password fields and the CSRF token aren't kept.
+func Flash(sess interfaces.Session, errs ValidationErrors, input map[string][]string) {
+	old := make(map[string][]string)
+	for name, values := range input {
+		if name == "_token" || strings.Contains(strings.ToLower(name), "password") {
+			continue
+		}
+		old[name] = values
+	}
+
+	b, _ := json.Marshal(errs)
+	sess.Flash(ErrorsKey, string(b))
+
+	b, _ = json.Marshal(old)
+	sess.Flash(OldInputKey, string(b))
+}
+
+// FlashedErrors returns errors flashed by the previous request, or empty
+// errors if there weren't any
+func FlashedErrors(sess interfaces.Session) ValidationErrors {
+	errs := make(ValidationErrors)
+	if sess != nil {
+		_ = json.Unmarshal([]byte(sess.Get(ErrorsKey)), &errs)
+	}
+	return errs
+}
+
+// OldInput returns the first value submitted for a field by the previous
+// request if it failed validation
+func OldInput(sess interfaces.Session, name string) string {
+	if sess == nil {
+		return ""
+	}
+
+	var old map[string][]string
+	_ = json.Unmarshal([]byte(sess.Get(OldInputKey)), &old)
+	if len(old[name]) == 0 {
+		return ""
+	}
+
+	return old[name][0]
+}
@@ -0,0 +1,339 @@
+package validation
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is used for messages when no requested locale has one
+const DefaultLocale = "en"
+
+// Field is passed to a Rule when checking a value
+type Field struct {
+	// Name is the field's name in errors, like "email" or "address.city"
+	Name string
+
+	// Value is the field's value, with pointers dereferenced
+	Value reflect.Value
+
+	// Param is the text after "=" in the rule, like "255" in "max=255"
+	Param string
+
+	// Struct is the struct containing the field
+	Struct reflect.Value
+}
+
+// Rule returns whether a field's value passes
+type Rule func(f Field) bool
+
+// ValidationErrors maps field names to their error messages
+type ValidationErrors map[string][]string
+
+func (e ValidationErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = e.First(field)
+	}
+
+	return strings.Join(messages, " ")
+}
+
+// Has returns whether the field has any errors
+func (e ValidationErrors) Has(field string) bool {
+	return len(e[field]) > 0
+}
+
+// First returns the field's first error, or an empty string
+func (e ValidationErrors) First(field string) string {
+	if len(e[field]) == 0 {
+		return ""
+	}
+	return e[field][0]
+}
+
+func (e ValidationErrors) add(field, message string) {
+	e[field] = append(e[field], message)
+}
+
+// Validator checks structs against the rules in their `validate` tags
+//
+// For example:
+//
+//	type SignUp struct {
+//		Email    string `form:"email" validate:"required,email,max=255"`
+//		Password string `form:"password" validate:"required,min=8,confirmed"`
+//		Role     string `form:"role" validate:"in=admin editor"`
+//	}
+type Validator struct {
+	rules    map[string]Rule
+	messages map[string]map[string]string
+	mutex    sync.RWMutex
+}
+
+// New returns a Validator with the built-in rules and English messages
+func New() *Validator {
+	v := &Validator{
+		rules:    make(map[string]Rule),
+		messages: make(map[string]map[string]string),
+	}
+
+	for name, rule := range builtinRules {
+		v.rules[name] = rule
+	}
+
+	v.Messages(DefaultLocale, defaultMessages)
+
+	return v
+}
+
+// Rule registers a rule along with its English message. Messages can use
+// :field and :param placeholders.
+//
+// For example:
+//
+//	v.Rule("slug", func(f validation.Field) bool {
+//		return slugRegexp.MatchString(f.Value.String())
+//	}, "The :field must be a valid slug.")
+func (v *Validator) Rule(name string, rule Rule, message string) {
+	v.mutex.Lock()
+	v.rules[name] = rule
+	v.mutex.Unlock()
+
+	v.Messages(DefaultLocale, map[string]string{name: message})
+}
+
+// Messages adds or replaces the messages for a locale, keyed by rule name
+func (v *Validator) Messages(locale string, messages map[string]string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	locale = normalizeLocale(locale)
+	if v.messages[locale] == nil {
+		v.messages[locale] = make(map[string]string)
+	}
+
+	for name, message := range messages {
+		v.messages[locale][name] = message
+	}
+}
+
+// Validate checks s, a struct or pointer to one, and returns
+// ValidationErrors if any rules fail. Messages use the first of locales
+// that has one for the rule, falling back to DefaultLocale.
+func (v *Validator) Validate(s interface{}, locales ...string) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		panic("Can only validate structs, got " + rv.Kind().String())
+	}
+
+	errs := make(ValidationErrors)
+	v.validateStruct(rv, "", locales, errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func (v *Validator) validateStruct(s reflect.Value, prefix string, locales []string, errs ValidationErrors) {
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		name = prefix + name
+
+		value := s.Field(i)
+		for value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+
+		if tag, ok := f.Tag.Lookup("validate"); ok {
+			v.validateField(Field{Name: name, Value: value, Struct: s}, tag, locales, errs)
+		}
+
+		switch value.Kind() {
+		case reflect.Struct:
+			v.validateStruct(value, name+".", locales, errs)
+		case reflect.Slice, reflect.Array:
+			for j := 0; j < value.Len(); j++ {
+				item := value.Index(j)
+				for item.Kind() == reflect.Ptr && !item.IsNil() {
+					item = item.Elem()
+				}
+				if item.Kind() == reflect.Struct {
+					v.validateStruct(item, name+"."+strconv.Itoa(j)+".", locales, errs)
+				}
+			}
+		}
+	}
+}
+
+func (v *Validator) validateField(field Field, tag string, locales []string, errs ValidationErrors) {
+	rules := strings.Split(tag, ",")
+
+	// Empty fields only need to pass the required rule
+	if isEmpty(field.Value) {
+		for _, r := range rules {
+			if strings.TrimSpace(r) == "required" {
+				errs.add(field.Name, v.message("required", field, locales))
+			}
+		}
+		return
+	}
+
+	for _, r := range rules {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		name, param := r, ""
+		if i := strings.Index(r, "="); i >= 0 {
+			name, param = r[:i], r[i+1:]
+		}
+
+		v.mutex.RLock()
+		rule, ok := v.rules[name]
+		v.mutex.RUnlock()
+		if !ok {
+			panic("Validation rule " + name + " is not registered")
+		}
+
+		field.Param = param
+		if !rule(field) {
+			errs.add(field.Name, v.message(name, field, locales))
+		}
+	}
+}
+
+func (v *Validator) message(rule string, f Field, locales []string) string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	message := ""
+	for _, locale := range append(locales, DefaultLocale) {
+		if m, ok := v.messages[normalizeLocale(locale)][rule]; ok {
+			message = m
+			break
+		}
+	}
+
+	if message == "" {
+		message = "The :field field is invalid."
+	}
+
+	return strings.NewReplacer(
+		":field", displayName(f.Name),
+		":param", f.Param,
+	).Replace(message)
+}
+
+// Locales returns the locales from an Accept-Language header, most
+// preferred first
+func Locales(acceptLanguage string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	var ws []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		pieces := strings.Split(strings.TrimSpace(part), ";")
+		locale := strings.TrimSpace(pieces[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, p := range pieces[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				q, _ = strconv.ParseFloat(p[2:], 64)
+			}
+		}
+
+		ws = append(ws, weighted{locale: locale, q: q})
+	}
+
+	sort.SliceStable(ws, func(i, j int) bool { return ws[i].q > ws[j].q })
+
+	// Also try the base language of each locale, so "fr-CA" can use "fr"
+	var locales []string
+	for _, w := range ws {
+		locales = append(locales, w.locale)
+		if i := strings.Index(w.locale, "-"); i > 0 {
+			locales = append(locales, w.locale[:i])
+		}
+	}
+
+	return locales
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(locale, "_", "-", -1))
+}
+
+// fieldName returns the name a field is bound from, preferring the json
+// tag, then the form tag, then the Go name
+func fieldName(f reflect.StructField) (string, bool) {
+	for _, tag := range []string{"json", "form", "query", "route"} {
+		value, ok := f.Tag.Lookup(tag)
+		if !ok {
+			continue
+		}
+
+		if value == "-" {
+			return "", false
+		}
+
+		if name := strings.Split(value, ",")[0]; name != "" {
+			return name, true
+		}
+	}
+
+	return f.Name, true
+}
+
+// displayName turns a field name like "address.postal_code" into
+// "postal code" for use in messages
+func displayName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.Replace(name, "_", " ", -1)
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package hemlock_test
+
+import (
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/validation"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testSignUp struct {
+	Email                string `json:"email" form:"email" validate:"required,email,max=255"`
+	Password             string `json:"password" form:"password" validate:"required,min=8,confirmed"`
+	PasswordConfirmation string `json:"password_confirmation" form:"password_confirmation"`
+	Role                 string `json:"role" form:"role" validate:"in=admin editor"`
+	Age                  *int   `json:"age" form:"age" validate:"required,min=18"`
+}
+
+func TestValidator(t *testing.T) {
+	v := validation.New()
+	v.Messages("fr", map[string]string{"required": "Le champ :field est obligatoire."})
+
+	age := 16
+	err := v.Validate(&testSignUp{
+		Email:                "not-an-email",
+		Password:             "secret",
+		PasswordConfirmation: "other",
+		Age:                  &age,
+	})
+	errs, ok := err.(validation.ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, "The email must be a valid email address.", errs.First("email"))
+	assert.Equal(t, []string{
+		"The password must be at least 8.",
+		"The password confirmation does not match.",
+	}, errs["password"])
+	assert.Equal(t, "The age must be at least 18.", errs.First("age"))
+	assert.False(t, errs.Has("role"), "Should skip rules for empty optional fields")
+
+	err = v.Validate(testSignUp{}, validation.Locales("fr-CA,fr;q=0.9,en;q=0.8")...)
+	assert.Equal(t, "Le champ email est obligatoire.", err.(validation.ValidationErrors).First("email"))
+
+	v.Rule("even", func(f validation.Field) bool { return f.Value.Int()%2 == 0 }, "The :field must be even.")
+	err = v.Validate(struct {
+		N int `validate:"even"`
+	}{N: 3})
+	assert.EqualError(t, err, "The N must be even.")
+}
+
+func TestValidation_Router(t *testing.T) {
+	r := newAuthTestRouter()
+	r.Post("/signup", func(s testSignUp, res interfaces.Response) interfaces.Result {
+		return res.Data(s.Email)
+	}).CSRFExempt()
+	r.Get("/errors", func(sess interfaces.Session, res interfaces.Response) interfaces.Result {
+		return res.Data(validation.FlashedErrors(sess).First("email") + "|" + validation.OldInput(sess, "email"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"email": "nope"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{
+		"message": "The given data was invalid.",
+		"errors": {
+			"email": ["The email must be a valid email address."],
+			"password": ["The password field is required."],
+			"age": ["The age field is required."]
+		}
+	}`, w.Body.String())
+
+	form := url.Values{"email": {"nope"}, "password": {"secret"}}
+	req = httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", "/signup")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusSeeOther, w.Code, "Should redirect forms back")
+	assert.Equal(t, "/signup", w.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/errors", nil)
+	addCookies(req, w.Result().Cookies())
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "The email must be a valid email address.|nope", w.Body.String(), "Should flash errors and input")
+
+	req = httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{
+		"email": "a@example.com",
+		"password": "long-enough",
+		"password_confirmation": "long-enough",
+		"age": 30
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "a@example.com", w.Body.String())
+}
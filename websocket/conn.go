@@ -0,0 +1,473 @@
+// Package websocket implements the WebSocket protocol from RFC 6455, with
+// compression from RFC 7692
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Close codes from RFC 6455 section 7.4.1
+const (
+	CloseNormal             = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatus           = 1005
+	CloseAbnormal           = 1006
+	CloseInvalidPayload     = 1007
+	ClosePolicyViolation    = 1008
+	CloseTooBig             = 1009
+	CloseMandatoryExtension = 1010
+	CloseInternalError      = 1011
+)
+
+// Frame opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// DefaultReadLimit is the largest message read unless SetReadLimit changes
+// it
+const DefaultReadLimit = 32 << 20
+
+// maxControlPayload is the most a ping, pong or close frame can carry
+const maxControlPayload = 125
+
+// ErrClosed is returned when writing after the connection was closed
+var ErrClosed = errors.New("websocket: connection closed")
+
+// CloseError is returned by ReadMessage once the connection is closed
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: closed with code %d: %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection. Reads and writes can each happen from
+// one goroutine at a time, but a read and a write can run concurrently.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// server connections read masked frames and write unmasked ones, and
+	// clients do the opposite
+	server      bool
+	compress    bool
+	subprotocol string
+	readLimit   int64
+
+	writeMutex sync.Mutex
+	closeSent  bool
+
+	handlerMutex sync.RWMutex
+	onPing       func(data []byte)
+	onPong       func(data []byte)
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, server, compress bool, subprotocol string) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+
+	return &Conn{
+		conn:        conn,
+		br:          br,
+		server:      server,
+		compress:    compress,
+		subprotocol: subprotocol,
+		readLimit:   DefaultReadLimit,
+	}
+}
+
+// Subprotocol returns the subprotocol agreed on in the handshake
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compressed returns whether messages are compressed with permessage-deflate
+func (c *Conn) Compressed() bool {
+	return c.compress
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadLimit sets the largest message that can be read. Larger messages
+// close the connection with CloseTooBig.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// OnPing sets a function called with the data of pings. They're answered
+// with a pong either way.
+func (c *Conn) OnPing(fn func(data []byte)) {
+	c.handlerMutex.Lock()
+	defer c.handlerMutex.Unlock()
+	c.onPing = fn
+}
+
+// OnPong sets a function called with the data of pongs
+func (c *Conn) OnPong(fn func(data []byte)) {
+	c.handlerMutex.Lock()
+	defer c.handlerMutex.Unlock()
+	c.onPong = fn
+}
+
+// ReadMessage reads the next text or binary message. Pings and pongs are
+// handled while waiting for it. Once the peer closes the connection, a
+// *CloseError is returned.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	compressed := false
+	var buf bytes.Buffer
+
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload, false); err != nil && err != ErrClosed {
+				return 0, nil, err
+			}
+			c.callHandler(c.onPing, f.payload)
+			continue
+		case opPong:
+			c.callHandler(c.onPong, f.payload)
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(f.payload)
+		case opContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case opText, opBinary:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = int(f.opcode)
+			compressed = f.rsv1
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if c.readLimit > 0 && int64(buf.Len()+len(f.payload)) > c.readLimit {
+			return 0, nil, c.fail(CloseTooBig, "message too big")
+		}
+
+		buf.Write(f.payload)
+		if f.fin {
+			break
+		}
+	}
+
+	data := buf.Bytes()
+	if compressed {
+		var err error
+		data, err = decompress(data, c.readLimit)
+		if err == errTooBig {
+			return 0, nil, c.fail(CloseTooBig, "message too big")
+		} else if err != nil {
+			return 0, nil, c.fail(CloseInvalidPayload, "invalid compressed data")
+		}
+	}
+
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidPayload, "invalid UTF-8")
+	}
+
+	return messageType, data, nil
+}
+
+// ReadJSON reads the next message and decodes it into v
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends a text or binary message
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+
+	if c.compress {
+		return c.writeFrame(byte(messageType), compress(data), true)
+	}
+
+	return c.writeFrame(byte(messageType), data, false)
+}
+
+// WriteJSON sends v encoded as a JSON text message
+func (c *Conn) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, b)
+}
+
+// Ping sends a ping, which the peer answers with a pong carrying the same
+// data
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: ping data too long")
+	}
+	return c.writeFrame(opPing, data, false)
+}
+
+// Close sends a close frame with the code and reason, then closes the
+// connection. It's safe to call more than once.
+func (c *Conn) Close(code int, reason string) error {
+	err := c.writeClose(code, reason)
+	c.conn.Close()
+	if err == ErrClosed {
+		return nil
+	}
+	return err
+}
+
+func (c *Conn) callHandler(fn func([]byte), data []byte) {
+	c.handlerMutex.RLock()
+	defer c.handlerMutex.RUnlock()
+	if fn != nil {
+		fn(data)
+	}
+}
+
+// handleClose answers a close frame from the peer and closes the
+// connection
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		closeErr = &CloseError{Code: CloseProtocolError, Reason: "invalid close frame"}
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) || !utf8.Valid(payload[2:]) {
+			closeErr = &CloseError{Code: CloseProtocolError, Reason: "invalid close frame"}
+		}
+	}
+
+	echo := closeErr.Code
+	if echo == CloseNoStatus {
+		echo = CloseNormal
+	}
+	_ = c.Close(echo, "")
+
+	return closeErr
+}
+
+// fail closes the connection because the peer broke the protocol
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+func (c *Conn) writeClose(code int, reason string) error {
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+
+	return c.writeFrame(opClose, payload, false)
+}
+
+// validCloseCode returns whether a peer may send the code. Some codes are
+// only for reporting and never sent.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < 1000 || code > 1014:
+		return false
+	}
+	return code != 1004 && code != CloseNoStatus && code != CloseAbnormal
+}
+
+type frame struct {
+	fin     bool
+	rsv1    bool
+	opcode  byte
+	payload []byte
+}
+
+func (c *Conn) readFrame() (*frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return nil, c.readError(err)
+	}
+
+	f := &frame{
+		fin:    header[0]&0x80 != 0,
+		rsv1:   header[0]&0x40 != 0,
+		opcode: header[0] & 0x0f,
+	}
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	if header[0]&0x30 != 0 || (f.rsv1 && (!c.compress || f.opcode == opContinuation || f.opcode >= opClose)) {
+		return nil, c.fail(CloseProtocolError, "unexpected reserved bits")
+	}
+
+	if masked != c.server {
+		return nil, c.fail(CloseProtocolError, "incorrect masking")
+	}
+
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return nil, c.readError(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return nil, c.readError(err)
+		}
+		length = binary.BigEndian.Uint64(b[:])
+	}
+
+	if f.opcode >= opClose && (length > maxControlPayload || !f.fin) {
+		return nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+
+	// Check before allocating so a huge length can't exhaust memory
+	if c.readLimit > 0 && length > uint64(c.readLimit) {
+		return nil, c.fail(CloseTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return nil, c.readError(err)
+		}
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return nil, c.readError(err)
+	}
+
+	if masked {
+		maskBytes(mask, f.payload)
+	}
+
+	return f, nil
+}
+
+// readError reports a connection that ended without a close frame as
+// CloseAbnormal
+func (c *Conn) readError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		c.conn.Close()
+		return &CloseError{Code: CloseAbnormal, Reason: "unexpected EOF"}
+	}
+	return err
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte, rsv1 bool) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+	if rsv1 {
+		header[0] |= 0x40
+	}
+
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	// Clients mask everything they send
+	if !c.server {
+		header[1] |= 0x80
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		header = append(header, mask[:]...)
+
+		masked := make([]byte, length)
+		copy(masked, payload)
+		maskBytes(mask, masked)
+		payload = masked
+	}
+
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// deflateTail is removed from the end of compressed messages, as RFC 7692
+// section 7.2.1 says
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+var errTooBig = errors.New("websocket: message too big")
+
+// compress deflates a message. Neither side keeps a compression context
+// between messages, so each one stands alone.
+func compress(data []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(data)
+	w.Flush()
+
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail)
+}
+
+func decompress(data []byte, limit int64) ([]byte, error) {
+	// Put back the tail and finish with an empty final block so the reader
+	// doesn't report an unexpected EOF
+	r := flate.NewReader(io.MultiReader(
+		bytes.NewReader(data),
+		bytes.NewReader(deflateTail),
+		bytes.NewReader([]byte{0x01, 0x00, 0x00, 0xff, 0xff}),
+	))
+	defer r.Close()
+
+	if limit <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	out, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, errTooBig
+	}
+
+	return out, nil
+}
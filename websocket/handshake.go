@@ -0,0 +1,353 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// acceptGUID is combined with the client's key to prove the server
+// understood the handshake
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// deflateExtension is the only extension offered and accepted
+const deflateExtension = "permessage-deflate; server_no_context_takeover; client_no_context_takeover"
+
+// Options configure the server side of a connection
+type Options struct {
+	// Subprotocols the server supports, in order of preference
+	Subprotocols []string
+
+	// CheckOrigin returns whether to accept a request's Origin. By default
+	// only browsers on the same host are accepted.
+	CheckOrigin func(r *http.Request) bool
+
+	// EnableCompression accepts permessage-deflate if the client offers it
+	EnableCompression bool
+
+	// ReadLimit is the largest message that can be read. Zero means
+	// DefaultReadLimit.
+	ReadLimit int64
+}
+
+// DialOptions configure the client side of a connection
+type DialOptions struct {
+	// Header is added to the handshake request, like for cookies
+	Header http.Header
+
+	// Subprotocols the client supports, in order of preference
+	Subprotocols []string
+
+	// EnableCompression offers permessage-deflate to the server
+	EnableCompression bool
+
+	// ReadLimit is the largest message that can be read. Zero means
+	// DefaultReadLimit.
+	ReadLimit int64
+
+	// TLSConfig is used for wss:// URLs
+	TLSConfig *tls.Config
+}
+
+// HandshakeError is returned by Upgrade when the request can't be upgraded.
+// Nothing has been written, so the caller should respond with Status.
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// IsUpgrade returns whether the request asks to be upgraded to a WebSocket
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade completes the server side of the handshake and takes over the
+// connection. Headers already set on w, like cookies, are sent with the
+// handshake response.
+func Upgrade(w http.ResponseWriter, r *http.Request, opts Options) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, &HandshakeError{http.StatusMethodNotAllowed, "handshake must be a GET request"}
+	}
+
+	if !IsUpgrade(r) {
+		return nil, &HandshakeError{http.StatusUpgradeRequired, "not a WebSocket handshake"}
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, &HandshakeError{http.StatusUpgradeRequired, "unsupported WebSocket version"}
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, &HandshakeError{http.StatusBadRequest, "invalid Sec-WebSocket-Key"}
+	}
+
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, &HandshakeError{http.StatusForbidden, "origin not allowed"}
+	}
+
+	subprotocol := chooseSubprotocol(r.Header, opts.Subprotocols)
+	compress := opts.EnableCompression && acceptsDeflate(r.Header.Values("Sec-WebSocket-Extensions"))
+
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, &HandshakeError{http.StatusInternalServerError, "response does not support hijacking"}
+	}
+
+	netConn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// The server may have set deadlines for a normal request
+	_ = netConn.SetDeadline(time.Time{})
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	buf.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		buf.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		buf.WriteString("Sec-WebSocket-Extensions: " + deflateExtension + "\r\n")
+	}
+	_ = w.Header().Write(&buf)
+	buf.WriteString("\r\n")
+
+	if _, err := netConn.Write(buf.Bytes()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	c := newConn(netConn, brw.Reader, true, compress, subprotocol)
+	if opts.ReadLimit != 0 {
+		c.readLimit = opts.ReadLimit
+	}
+
+	return c, nil
+}
+
+// Dial connects to a ws:// or wss:// URL. The handshake response is
+// returned even if it fails, so its status can be checked.
+func Dial(ctx context.Context, rawURL string, opts DialOptions) (*Conn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme = "https"
+		secure = true
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme %s", u.Scheme)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		if secure {
+			addr += ":443"
+		} else {
+			addr += ":80"
+		}
+	}
+
+	var d net.Dialer
+	netConn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if secure {
+		cfg := opts.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName = u.Hostname()
+		}
+
+		tlsConn := tls.Client(netConn, cfg)
+		if err := tlsConn.Handshake(); err != nil {
+			netConn.Close()
+			return nil, nil, err
+		}
+		netConn = tlsConn
+	}
+
+	c, resp, err := clientHandshake(ctx, netConn, u, opts)
+	if err != nil {
+		netConn.Close()
+		return nil, resp, err
+	}
+
+	return c, resp, nil
+}
+
+func clientHandshake(ctx context.Context, netConn net.Conn, u *url.URL, opts DialOptions) (*Conn, *http.Response, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = netConn.SetDeadline(deadline)
+		defer netConn.SetDeadline(time.Time{})
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(b)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range opts.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(opts.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(opts.Subprotocols, ", "))
+	}
+	if opts.EnableCompression {
+		req.Header.Set("Sec-WebSocket-Extensions", deflateExtension)
+	}
+
+	if err := req.Write(netConn); err != nil {
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, resp, &HandshakeError{resp.StatusCode, "bad handshake status " + resp.Status}
+	}
+
+	if !headerContains(resp.Header, "Upgrade", "websocket") ||
+		!headerContains(resp.Header, "Connection", "upgrade") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, resp, errors.New("websocket: bad handshake response")
+	}
+
+	compress := false
+	if ext := resp.Header.Values("Sec-WebSocket-Extensions"); len(ext) > 0 {
+		if !opts.EnableCompression || !acceptsDeflate(ext) {
+			return nil, resp, errors.New("websocket: server chose an extension that wasn't offered")
+		}
+		compress = true
+	}
+
+	c := newConn(netConn, br, false, compress, resp.Header.Get("Sec-WebSocket-Protocol"))
+	if opts.ReadLimit != 0 {
+		c.readLimit = opts.ReadLimit
+	}
+
+	return c, resp, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sameOrigin accepts requests without an Origin, which don't come from
+// browsers, and ones from the host being connected to
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func chooseSubprotocol(h http.Header, supported []string) string {
+	for _, offered := range headerTokens(h, "Sec-WebSocket-Protocol") {
+		for _, s := range supported {
+			if offered == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// acceptsDeflate returns whether one of the extension offers is
+// permessage-deflate with parameters we can honour. Go's flate always uses
+// the largest window, so offers limiting the server's window are skipped.
+func acceptsDeflate(values []string) bool {
+	for _, value := range values {
+		for _, offer := range strings.Split(value, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+
+			ok := true
+			for _, p := range params[1:] {
+				p = strings.TrimSpace(p)
+				if strings.HasPrefix(p, "server_max_window_bits") && p != "server_max_window_bits=15" {
+					ok = false
+				}
+			}
+
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
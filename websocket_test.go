@@ -0,0 +1,96 @@
+package hemlock_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gschier/hemlock/interfaces"
+	"github.com/gschier/hemlock/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dialTestServer(t *testing.T, srv *httptest.Server, path string, opts websocket.DialOptions) (*websocket.Conn, *http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+path, opts)
+}
+
+func TestRouter_WebSocket(t *testing.T) {
+	r := newAuthTestRouter()
+	r.WebSocket("/echo/{room}", func(conn interfaces.WebSocketConn, req interfaces.Request) error {
+		conn.WriteMessage(websocket.TextMessage, []byte("joined "+req.Param("room")))
+		for {
+			t, data, err := conn.ReadMessage()
+			if err != nil {
+				return nil
+			}
+			if err := conn.WriteMessage(t, data); err != nil {
+				return err
+			}
+		}
+	}, websocket.Options{Subprotocols: []string{"chat"}, EnableCompression: true})
+	r.WebSocket("/private", func(conn interfaces.WebSocketConn) {}).Middleware("auth")
+	r.WebSocket("/broken", func(conn interfaces.WebSocketConn) error {
+		return errors.New("broken")
+	})
+
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	for _, compress := range []bool{false, true} {
+		conn, resp, err := dialTestServer(t, srv, "/echo/lobby", websocket.DialOptions{
+			Subprotocols:      []string{"other", "chat"},
+			EnableCompression: compress,
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "chat", conn.Subprotocol())
+		assert.Equal(t, compress, conn.Compressed())
+		assert.NotEmpty(t, resp.Header.Get("Set-Cookie"), "Should send session cookie with handshake")
+
+		_, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, "joined lobby", string(data), "Should inject route params")
+
+		pong := make(chan string, 1)
+		conn.OnPong(func(data []byte) { pong <- string(data) })
+		assert.NoError(t, conn.Ping([]byte("hi")))
+
+		big := strings.Repeat("hemlock ", 20000)
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(big)))
+		msgType, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, websocket.TextMessage, msgType)
+		assert.Equal(t, big, string(data))
+		assert.Equal(t, "hi", <-pong)
+
+		assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte{0, 1, 2}))
+		msgType, data, err = conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, websocket.BinaryMessage, msgType)
+		assert.Equal(t, []byte{0, 1, 2}, data)
+
+		assert.NoError(t, conn.Close(websocket.CloseNormal, "bye"))
+	}
+
+	_, resp, err := dialTestServer(t, srv, "/private", websocket.DialOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Should run middleware before upgrading")
+
+	conn, _, err := dialTestServer(t, srv, "/broken", websocket.DialOptions{})
+	if assert.NoError(t, err) {
+		_, _, err = conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		assert.True(t, errors.As(err, &closeErr))
+		assert.Equal(t, websocket.CloseInternalError, closeErr.Code, "Should close with callback errors")
+	}
+
+	res, err := http.Get(srv.URL + "/echo/lobby")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUpgradeRequired, res.StatusCode)
+}